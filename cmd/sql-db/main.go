@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	dpsql "github.com/NividhSingh/sql-db"
+)
+
+// databasePath is where the tables live between runs.
+const databasePath = "database.json"
+
+// options are the settings of a run, taken from the command line. The
+// defaults are the values this program has always used.
+type options struct {
+	inputPath   string
+	interactive bool
+	format      string
+
+	db dpsql.Options
+}
+
+// parseOptions reads the command line into options.
+func parseOptions(args []string) (options, error) {
+	opts := options{db: dpsql.DefaultOptions()}
+	opts.db.Path = databasePath
+	opts.db.Log = os.Stdout
+	var quasiIDs, sensitive string
+	var seed int64
+	var mechanism string
+	flags := flag.NewFlagSet("sql-db", flag.ContinueOnError)
+	flags.StringVar(&opts.inputPath, "input", "input.sql", "SQL script to run (- for stdin)")
+	flags.BoolVar(&opts.interactive, "i", false, "start an interactive shell instead of running the input script")
+	flags.Float64Var(&opts.db.EpsilonBudget, "epsilon", opts.db.EpsilonBudget, "total ε budget shared by all SELECTs")
+	flags.Float64Var(&opts.db.DecayRate, "decay", opts.db.DecayRate, "factor between one SELECT's ε and the next, in (0, 1)")
+	flags.StringVar(&mechanism, "mechanism", string(opts.db.Mechanism), "noise mechanism: laplace or gaussian")
+	flags.Float64Var(&opts.db.DeltaBudget, "delta", opts.db.DeltaBudget, "total δ budget shared by all SELECTs, for the gaussian mechanism")
+	flags.StringVar(&opts.db.Analyst, "analyst", defaultAnalyst(), "who the SELECTs are charged to in the budget ledger")
+	flags.Float64Var(&opts.db.MinEpsilon, "min-epsilon", opts.db.MinEpsilon, "refuse SELECTs whose share of ε would be smaller than this")
+	flags.IntVar(&opts.db.K, "k", opts.db.K, "k-anonymity: drop rows whose quasi-identifiers occur fewer than k times")
+	flags.StringVar(&quasiIDs, "quasi", strings.Join(opts.db.QuasiIDs, ","), "comma separated quasi-identifier columns")
+	flags.IntVar(&opts.db.L, "l", opts.db.L, "l-diversity: drop sensitive columns with fewer than l distinct values")
+	flags.StringVar(&sensitive, "sensitive", strings.Join(opts.db.Sensitive, ","), "comma separated sensitive columns")
+	flags.Int64Var(&seed, "seed", 0, "seed for the noise, to make a run repeatable; not for real data (0 uses crypto/rand)")
+	flags.StringVar(&opts.format, "format", "table", "output format for SELECT results: table, csv or json")
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+	if flags.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	opts.db.QuasiIDs = dpsql.SplitNames(quasiIDs)
+	opts.db.Sensitive = dpsql.SplitNames(sensitive)
+	opts.db.Mechanism = dpsql.Mechanism(mechanism)
+	if seed != 0 {
+		opts.db.Noise = dpsql.NewSeededSource(seed)
+	}
+	// the privacy settings are checked by dpsql.Open
+	switch opts.format {
+	case "table", "csv", "json":
+	default:
+		return opts, fmt.Errorf("-format must be table, csv or json, got %q", opts.format)
+	}
+	return opts, nil
+}
+
+// defaultAnalyst is the login name, so that each user of a shared database
+// file has a budget of their own without saying who they are.
+func defaultAnalyst() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "default"
+}
+
+func main() {
+	opts, err := parseOptions(os.Args[1:])
+	if err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Println(err)
+		}
+		os.Exit(2)
+	}
+
+	db, err := dpsql.Open(opts.db)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}()
+
+	// the AST dump would get in the way of csv/json output
+	verbose := opts.format == "table" && !opts.interactive
+
+	// a fresh database starts from seed.sql
+	if db.Created() {
+		seed, err := os.ReadFile("seed.sql")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("Error reading file:", err)
+			return
+		}
+		if !runScript(db, opts.format, string(seed), "seed.sql", verbose) {
+			return
+		}
+	}
+
+	if opts.interactive {
+		runREPL(db, opts.format)
+		return
+	}
+
+	// Read SQL commands from file
+	var data []byte
+	if opts.inputPath == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(opts.inputPath)
+	}
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return
+	}
+	name := opts.inputPath
+	if name == "-" {
+		name = "stdin"
+	}
+	runScript(db, opts.format, string(data), name, verbose)
+}
+
+// runScript parses and runs every statement in command, printing the ASTs
+// first if verbose is set. Syntax errors are reported against name, and the
+// statements around them still run. It returns false if the database can't
+// take any more statements.
+func runScript(db *dpsql.DB, format string, command string, name string, verbose bool) bool {
+	statements, parseErrors := dpsql.Parse(command)
+	for _, err := range parseErrors {
+		fmt.Printf("%s: %v\n", name, err)
+	}
+
+	if verbose {
+		for _, statement := range statements {
+			statement.WriteAST(os.Stdout)
+		}
+	}
+
+	for _, statement := range statements {
+		if !runStatement(db, format, statement) {
+			return false
+		}
+	}
+	return true
+}
+
+// runStatement runs one statement and prints what it did. A statement that
+// fails is reported and the caller carries on, unless the log can no longer
+// be written, in which case it returns false.
+func runStatement(db *dpsql.DB, format string, statement dpsql.Statement) bool {
+	if !statement.IsQuery() {
+		result, err := db.ExecStatement(statement)
+		if err != nil {
+			fmt.Println(err)
+			return !errors.Is(err, dpsql.ErrLogFailed)
+		}
+		if result.Message != "" {
+			fmt.Println(result.Message)
+		}
+		return true
+	}
+
+	rows, err := db.QueryStatement(statement)
+	if err != nil {
+		fmt.Println(err)
+		return true
+	}
+	// the banner would break csv/json output, so it only goes with tables,
+	// and SHOW BUDGET spends nothing to report
+	if format == "table" && rows.Epsilon > 0 {
+		budget := db.Budget()
+		fmt.Printf("\n-- SELECT #%d: ε=%.4f δ=%.3g  (cumulative budget used ≈ ε %.4f, δ %.3g)\n",
+			budget.Queries, rows.Epsilon, rows.Delta, budget.Used, budget.DeltaUsed)
+	}
+	printResult(rows, format)
+	return true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	dpsql "github.com/NividhSingh/sql-db"
+)
+
+// printResult prints a SELECT result as an ASCII table, CSV or JSON.
+func printResult(rows *dpsql.Rows, format string) {
+	switch format {
+	case "csv":
+		printCSV(rows)
+	case "json":
+		printJSON(rows)
+	default:
+		printTable(rows)
+	}
+}
+
+// printTable prints a result in grid format.
+func printTable(rows *dpsql.Rows) {
+	if len(rows.Columns) == 0 {
+		fmt.Println("Empty result")
+		return
+	}
+
+	// compute widths based on the column labels
+	widths := make([]int, len(rows.Columns))
+	for i, col := range rows.Columns {
+		widths[i] = len(col.Name)
+	}
+	for _, values := range rows.Values {
+		for i, v := range values {
+			cell := fmt.Sprintf("%v", v)
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+
+	// separator line
+	sep := "+"
+	for _, w := range widths {
+		sep += strings.Repeat("-", w+2) + "+"
+	}
+
+	// header
+	header := "|"
+	for i, col := range rows.Columns {
+		header += " " + fmt.Sprintf("%-*s", widths[i], col.Name) + " |"
+	}
+
+	fmt.Println(sep)
+	fmt.Println(header)
+	fmt.Println(sep)
+	for _, values := range rows.Values {
+		line := "|"
+		for i, v := range values {
+			cell := fmt.Sprintf("%v", v)
+			line += " " + fmt.Sprintf("%-*s", widths[i], cell) + " |"
+		}
+		fmt.Println(line)
+	}
+	fmt.Println(sep)
+}
+
+// printCSV prints a header line of labels followed by one line per row.
+// NULL is an empty field.
+func printCSV(rows *dpsql.Rows) {
+	w := csv.NewWriter(os.Stdout)
+	labels := make([]string, len(rows.Columns))
+	for i, col := range rows.Columns {
+		labels[i] = col.Name
+	}
+	w.Write(labels)
+	for _, values := range rows.Values {
+		record := make([]string, len(values))
+		for i, v := range values {
+			if v != nil {
+				record[i] = fmt.Sprintf("%v", v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// printJSON prints the rows as a JSON array of objects, keeping the columns
+// in SELECT order.
+func printJSON(rows *dpsql.Rows) {
+	var b strings.Builder
+	b.WriteString("[")
+	for ri, values := range rows.Values {
+		if ri > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for i, v := range values {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			key, _ := json.Marshal(rows.Columns[i].Name)
+			value, err := json.Marshal(v)
+			if err != nil {
+				// NaN and ±Inf have no JSON form
+				value, _ = json.Marshal(fmt.Sprintf("%v", v))
+			}
+			b.Write(key)
+			b.WriteString(": ")
+			b.Write(value)
+		}
+		b.WriteString("}")
+	}
+	if len(rows.Values) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]")
+	fmt.Println(b.String())
+}
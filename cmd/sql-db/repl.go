@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	dpsql "github.com/NividhSingh/sql-db"
+)
+
+// historyPath keeps the shell's statements between runs.
+const historyPath = ".sql_history"
+
+// maxHistory is how many entries of historyPath are loaded at startup.
+const maxHistory = 500
+
+const (
+	prompt             = "sql> "
+	continuationPrompt = "...> "
+)
+
+// runREPL reads statements from stdin until .quit or end of input. A
+// statement can span several lines and ends at a ';' outside quotes. Lines
+// starting with '.' are meta-commands.
+func runREPL(db *dpsql.DB, format string) {
+	history := loadHistory(historyPath)
+	historyFile, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		fmt.Println("History won't be saved:", err)
+	} else {
+		defer historyFile.Close()
+	}
+	remember := func(entry string) {
+		history = append(history, entry)
+		if historyFile != nil {
+			// one entry per line, with the newlines of multi-line
+			// statements escaped
+			fmt.Fprintln(historyFile, historyEscaper.Replace(entry))
+		}
+	}
+
+	fmt.Println(`Enter SQL statements terminated by ";", or ".help" for commands.`)
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var statement strings.Builder
+	for {
+		if statement.Len() == 0 {
+			fmt.Print(prompt)
+		} else {
+			fmt.Print(continuationPrompt)
+		}
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		line := scanner.Text()
+
+		if statement.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+				continue
+			}
+			if strings.HasPrefix(trimmed, ".") {
+				remember(trimmed)
+				if !runMetaCommand(db, trimmed, history) {
+					return
+				}
+				continue
+			}
+		}
+
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		if !statementComplete(statement.String()) {
+			continue
+		}
+
+		input := strings.TrimSpace(statement.String())
+		statement.Reset()
+		remember(input)
+		if !runStatements(db, format, input) {
+			return
+		}
+	}
+}
+
+// runStatements parses and runs what was typed at the prompt. A statement
+// with a syntax error is reported and the others still run. It returns false
+// if the database can't take any more statements.
+func runStatements(db *dpsql.DB, format string, input string) bool {
+	statements, parseErrors := dpsql.Parse(input)
+	for _, parseErr := range parseErrors {
+		fmt.Println("Syntax error:", parseErr)
+	}
+
+	for _, statement := range statements {
+		if !runStatement(db, format, statement) {
+			return false
+		}
+	}
+	return true
+}
+
+// statementComplete reports whether input ends with a ';' that isn't inside
+// a string literal, a quoted identifier or a comment. Comments after the ';'
+// don't count.
+func statementComplete(input string) bool {
+	var quote byte // the quote we're inside, or 0
+	complete := false
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			complete = false
+		case c == '-' && strings.HasPrefix(input[i:], "--"):
+			end := strings.IndexByte(input[i:], '\n')
+			if end < 0 {
+				return complete
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(input[i:], "/*"):
+			end := strings.Index(input[i+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			i += 2 + end + 1
+		case c == ';':
+			complete = true
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			complete = false
+		}
+	}
+	return complete && quote == 0
+}
+
+// runMetaCommand runs a '.' command and returns false when the shell should
+// exit.
+func runMetaCommand(db *dpsql.DB, line string, history []string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".quit", ".exit":
+		return false
+
+	case ".help":
+		fmt.Println(".tables          list tables")
+		fmt.Println(".schema [NAME]   show the CREATE TABLE for one or every table")
+		fmt.Println(".budget          show the privacy budget spent and left")
+		fmt.Println(".history         show previous statements")
+		fmt.Println(".quit            save and exit")
+
+	case ".tables":
+		tables := db.Tables()
+		if len(tables) == 0 {
+			fmt.Println("No tables")
+		}
+		for _, table := range tables {
+			fmt.Println(table)
+		}
+
+	case ".schema":
+		names := fields[1:]
+		if len(names) == 0 {
+			names = db.Tables()
+		}
+		for _, name := range names {
+			schema, err := db.Schema(name)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			fmt.Println(schema)
+		}
+
+	case ".budget":
+		budget := db.Budget()
+		fmt.Printf("analyst:       %s\n", budget.Analyst)
+		fmt.Printf("SELECTs run:   %d\n", budget.Queries)
+		fmt.Printf("ε used:        %.4f of %.4f\n", budget.Used, budget.Total)
+		fmt.Printf("ε remaining:   %.4f\n", budget.Remaining)
+		fmt.Printf("next SELECT ε: %.4f\n", budget.Next)
+		fmt.Printf("δ used:        %.3g of %.3g\n", budget.DeltaUsed, budget.DeltaTotal)
+		fmt.Printf("δ remaining:   %.3g\n", budget.DeltaRemaining)
+		fmt.Printf("next SELECT δ: %.3g\n", budget.DeltaNext)
+		if budget.Exhausted {
+			fmt.Println("the budget is exhausted: further SELECTs will be refused")
+		}
+
+	case ".history":
+		for i, entry := range history {
+			fmt.Printf("%5d  %s\n", i+1, entry)
+		}
+
+	default:
+		fmt.Printf("Unknown command %s, try .help\n", fields[0])
+	}
+	return true
+}
+
+// historyEscaper writes an entry on one line of the history file, keeping
+// its newlines (which end a -- comment) and the whitespace inside string
+// literals; historyUnescaper reads it back.
+var (
+	historyEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+	historyUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r")
+)
+
+// loadHistory reads the last maxHistory entries of a history file.
+func loadHistory(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	for i, line := range lines {
+		lines[i] = historyUnescaper.Replace(line)
+	}
+	return lines
+}
@@ -0,0 +1,693 @@
+// Package dpsql is a small SQL engine that answers SELECTs with differential
+// privacy: aggregates get Laplace noise drawn from a decaying ε budget, and
+// results are filtered for k-anonymity and l-diversity.
+//
+//	db, err := dpsql.Open(dpsql.DefaultOptions())
+//	...
+//	defer db.Close()
+//	if _, err := db.Exec("CREATE TABLE t (x INT); INSERT INTO t VALUES (1);"); err != nil {
+//		...
+//	}
+//	rows, err := db.Query("SELECT COUNT(x) FROM t;")
+package dpsql
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// total budget of ε across all SELECTs:
+	maxEpsilonBudget = 10.0
+	// decayRate r: each query’s ε_n is multiplied by r relative to the prior
+	decayRate = 0.5
+	// total budget of δ across all SELECTs, spent only by the Gaussian
+	// mechanism and shared out with the same decay as ε
+	maxDeltaBudget = 1e-5
+	// the analyst queries are charged to when none is given
+	defaultAnalyst = "default"
+)
+
+// Mechanism is how noise is added to a SELECT's aggregates.
+type Mechanism string
+
+const (
+	// Laplace gives each aggregate Laplace noise scaled to its L1
+	// sensitivity. It spends no δ.
+	Laplace Mechanism = "laplace"
+	// Gaussian gives every aggregate in a row Gaussian noise scaled to the
+	// L2 sensitivity of the whole row, which grows more slowly than the L1
+	// sensitivity when a GROUP BY selects many aggregates. It spends δ.
+	Gaussian Mechanism = "gaussian"
+)
+
+// Options are the settings of a DB.
+type Options struct {
+	// Path is the database file; its write-ahead log sits next to it with
+	// a .wal extension. An empty Path keeps everything in memory. Only one
+	// DB at a time may have a Path open, in this process or any other.
+	Path string
+
+	// EpsilonBudget is the total ε shared by all SELECTs, and DecayRate, in
+	// (0, 1), the factor between one SELECT's ε and the next.
+	EpsilonBudget float64
+	DecayRate     float64
+
+	// Mechanism is Laplace or Gaussian; empty means Laplace. DeltaBudget is
+	// the total δ the Gaussian mechanism shares out like EpsilonBudget.
+	Mechanism   Mechanism
+	DeltaBudget float64
+
+	// K drops result rows whose QuasiIDs, of the columns a result shows,
+	// cover fewer than K rows by their noisy counts; empty QuasiIDs means
+	// every group key. L drops Sensitive columns with fewer than L distinct
+	// values.
+	K         int
+	QuasiIDs  []string
+	L         int
+	Sensitive []string
+
+	// Analyst is who is running the queries. Each analyst has a budget of
+	// their own, kept in a ledger next to the database file so it carries
+	// over from one Open to the next; empty means "default". A SELECT whose
+	// share of the budget would be below MinEpsilon, or go over the total,
+	// is refused. The name is taken on trust: it isn't access control, so
+	// set it for analysts rather than letting them choose it.
+	Analyst    string
+	MinEpsilon float64
+
+	// Noise is where noise gets its randomness; nil means crypto/rand. Use
+	// NewSeededSource to make a run repeatable.
+	Noise NoiseSource
+
+	// Log receives notices about recovery; nil discards them.
+	Log io.Writer
+}
+
+// DefaultOptions returns the settings the command-line tool has always
+// used, in memory.
+func DefaultOptions() Options {
+	return Options{
+		EpsilonBudget: maxEpsilonBudget,
+		DecayRate:     decayRate,
+		Mechanism:     Laplace,
+		DeltaBudget:   maxDeltaBudget,
+		Analyst:       defaultAnalyst,
+		MinEpsilon:    minQueryEpsilon,
+		K:             10,
+		QuasiIDs:      []string{"blood_type", "male_or_female"},
+		L:             3,
+		Sensitive:     []string{"has_diabetes", "sex"},
+	}
+}
+
+// SplitNames splits a comma separated list of column names such as
+// "a, b,c", dropping empty ones, for Options.QuasiIDs and Options.Sensitive.
+func SplitNames(list string) []string {
+	names := []string{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ErrLogFailed is returned (wrapped) once a statement couldn't be written to
+// the write-ahead log. The DB refuses further changes, since they could no
+// longer be recovered after a crash.
+var ErrLogFailed = errors.New("Error writing to write-ahead log")
+
+// DB is an open database. It is safe for concurrent use; statements run one
+// at a time.
+type DB struct {
+	mu     sync.Mutex
+	opts   Options
+	log    io.Writer
+	noise  NoiseSource
+	tables map[string]dbTable
+	// the open transaction, or nil outside BEGIN ... COMMIT
+	tx  *transaction
+	wal *writeAheadLog
+	// whether Open started from an empty database
+	created bool
+	// what every analyst has spent
+	ledger *ledger
+	// set once the log can't be written
+	failed error
+}
+
+// Open loads the database at opts.Path and replays whatever its log has on
+// top of it, or starts an empty one.
+func Open(opts Options) (*DB, error) {
+	if opts.EpsilonBudget <= 0 {
+		return nil, fmt.Errorf("epsilon budget must be positive, got %v", opts.EpsilonBudget)
+	}
+	if opts.DecayRate <= 0 || opts.DecayRate >= 1 {
+		return nil, fmt.Errorf("decay rate must be between 0 and 1, got %v", opts.DecayRate)
+	}
+	switch opts.Mechanism {
+	case "":
+		opts.Mechanism = Laplace
+	case Laplace:
+	case Gaussian:
+		if opts.DeltaBudget <= 0 || opts.DeltaBudget >= 1 {
+			return nil, fmt.Errorf("delta budget must be between 0 and 1, got %v", opts.DeltaBudget)
+		}
+	default:
+		return nil, fmt.Errorf("unknown noise mechanism %q, use laplace or gaussian", opts.Mechanism)
+	}
+	if opts.K < 0 || opts.L < 0 {
+		return nil, errors.New("k and l can't be negative")
+	}
+	if opts.MinEpsilon < 0 {
+		return nil, fmt.Errorf("minimum epsilon can't be negative, got %v", opts.MinEpsilon)
+	}
+	if opts.Analyst == "" {
+		opts.Analyst = defaultAnalyst
+	}
+
+	db := &DB{opts: opts, log: opts.Log, tables: make(map[string]dbTable), created: true}
+	if db.log == nil {
+		db.log = io.Discard
+	}
+	db.noise = opts.Noise
+	if db.noise == nil {
+		db.noise = NewCryptoSource()
+	}
+	if opts.Path == "" {
+		db.ledger, _ = openLedger("", db.log)
+		return db, nil
+	}
+
+	// lock the log before reading anything, so no other handle can
+	// checkpoint between loading the tables and replaying the log
+	walPath := walPathFor(opts.Path)
+	wal, err := openWriteAheadLog(walPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening write-ahead log: %v", err)
+	}
+	ledger, err := openLedger(ledgerPathFor(opts.Path), db.log)
+	if err != nil {
+		wal.close()
+		return nil, fmt.Errorf("Error opening budget ledger: %v", err)
+	}
+	db.ledger = ledger
+	tables, existed, checkpointLSN, err := openDatabase(opts.Path)
+	if err != nil {
+		wal.close()
+		ledger.close()
+		return nil, fmt.Errorf("Error loading database: %v", err)
+	}
+	db.tables = tables
+	replay, err := wal.recover(checkpointLSN, db.log)
+	if err != nil {
+		wal.close()
+		ledger.close()
+		return nil, fmt.Errorf("Error opening write-ahead log: %v", err)
+	}
+	db.wal = wal
+	if len(replay) > 0 {
+		db.replayStatements(replay)
+		fmt.Fprintf(db.log, "Recovered %d statements from %s\n", len(replay), walPath)
+		// a transaction the crash left open never committed
+		if db.tx != nil {
+			db.endOpenTransaction()
+		}
+	}
+	db.created = !existed && wal.lastLSN == 0
+	return db, nil
+}
+
+// Created reports whether Open started from an empty database, so the caller
+// can seed it.
+func (db *DB) Created() bool {
+	return db.created
+}
+
+// Close rolls back a transaction left open, saves the database and closes
+// the log and the budget ledger.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.wal == nil {
+		if db.tx != nil {
+			db.rollbackTransaction()
+		}
+		return db.ledger.close()
+	}
+	if db.tx != nil {
+		db.endOpenTransaction()
+	}
+	var err error
+	if db.wal.pending > 0 {
+		if err = db.wal.checkpoint(db.opts.Path, db.tables); err != nil {
+			err = fmt.Errorf("Error saving database: %v", err)
+		}
+	}
+	if closeErr := db.wal.close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := db.ledger.close(); err == nil {
+		err = closeErr
+	}
+	db.wal = nil
+	return err
+}
+
+// Statement is one parsed SQL statement.
+type Statement struct {
+	// SQL is the statement's text, as written
+	SQL  string
+	node *astNode
+}
+
+// IsQuery reports whether the statement is a SELECT or SHOW BUDGET, to be
+// run with QueryStatement rather than ExecStatement.
+func (s Statement) IsQuery() bool {
+	return s.node.Type == astSelect || s.node.Type == astShowBudget
+}
+
+// WriteAST writes the statement's syntax tree to w, for debugging.
+func (s Statement) WriteAST(w io.Writer) {
+	printAST(w, s.node, 0)
+}
+
+// Parse splits sql into statements. A statement with a syntax error is
+// reported and left out; the ones around it are still returned.
+func Parse(sql string) ([]Statement, []*ParseError) {
+	nodes, parseErrors := parseCommands(tokenize(sql))
+	statements := make([]Statement, len(nodes))
+	for i, node := range nodes {
+		statements[i] = Statement{SQL: statementSource(sql, node), node: node}
+	}
+	return statements, parseErrors
+}
+
+// Result is what a statement that isn't a SELECT did.
+type Result struct {
+	RowsAffected int64
+	// Message describes the change, e.g. "Inserted row into t", one line per
+	// table touched.
+	Message string
+}
+
+// ResultColumn describes one column of a query result.
+type ResultColumn struct {
+	// Name is the column's label: its alias, or a generated one such as
+	// count_age for aggregates.
+	Name string
+	// Type is INT, FLOAT or VARCHAR.
+	Type string
+}
+
+// Rows is the result of a SELECT, after noise, k-anonymity and l-diversity.
+// Values hold int64, float64, string or nil for NULL.
+type Rows struct {
+	Columns []ResultColumn
+	Values  [][]interface{}
+	// Epsilon and Delta are the share of the privacy budget the query
+	// spent. Delta is 0 under the Laplace mechanism.
+	Epsilon float64
+	Delta   float64
+}
+
+// Exec runs every statement in sql, stopping at the first that fails. A
+// syntax error anywhere means nothing runs. SELECTs must go through Query.
+func (db *DB) Exec(sql string) (Result, error) {
+	statements, parseErrors := Parse(sql)
+	if len(parseErrors) > 0 {
+		return Result{}, parseErrors[0]
+	}
+	var total Result
+	var messages []string
+	for _, statement := range statements {
+		result, err := db.ExecStatement(statement)
+		if err != nil {
+			return total, err
+		}
+		total.RowsAffected += result.RowsAffected
+		if result.Message != "" {
+			messages = append(messages, result.Message)
+		}
+	}
+	total.Message = strings.Join(messages, "\n")
+	return total, nil
+}
+
+// Query runs a single SELECT.
+func (db *DB) Query(sql string) (*Rows, error) {
+	statements, parseErrors := Parse(sql)
+	if len(parseErrors) > 0 {
+		return nil, parseErrors[0]
+	}
+	if len(statements) != 1 {
+		return nil, fmt.Errorf("Query takes one statement, got %d", len(statements))
+	}
+	return db.QueryStatement(statements[0])
+}
+
+// ExecStatement runs a parsed statement that changes the database. It is
+// written to the log before it touches the tables.
+func (db *DB) ExecStatement(statement Statement) (result Result, err error) {
+	if statement.IsQuery() {
+		return Result{}, errors.New("Exec can't run a SELECT, use Query")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.failed != nil {
+		return Result{}, db.failed
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if db.wal != nil {
+		if err := db.wal.append(statement.SQL); err != nil {
+			db.failed = fmt.Errorf("%w: %v", ErrLogFailed, err)
+			return Result{}, db.failed
+		}
+	}
+	result, err = db.apply(statement.node)
+	// uncommitted changes never reach the database file
+	if db.wal != nil && db.tx == nil {
+		if err := db.wal.checkpointIfDue(db.opts.Path, db.tables); err != nil {
+			fmt.Fprintln(db.log, "Error saving database:", err)
+		}
+	}
+	return result, err
+}
+
+// QueryStatement runs a parsed SELECT, spending the next share of the
+// analyst's privacy budget on it, or SHOW BUDGET.
+func (db *DB) QueryStatement(statement Statement) (rows *Rows, err error) {
+	if !statement.IsQuery() {
+		return nil, errors.New("Query can only run a SELECT, use Exec")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	astNode := statement.node
+	var result dbTable
+	var sensitivities map[string]aggregateSensitivity
+	if astNode.Type != astShowBudget {
+		result, sensitivities = db.selectFromAST(astNode)
+	}
+
+	// hold the ledger from reading the budget until this SELECT's share is
+	// on disk
+	if err := db.ledger.lock(); err != nil {
+		return nil, fmt.Errorf("Error reading budget ledger: %v", err)
+	}
+	defer db.ledger.unlock()
+	if astNode.Type == astShowBudget {
+		return db.showBudget(), nil
+	}
+
+	// a SELECT that got this far spends its share of the budget, which is
+	// on disk before any answer leaves
+	budget := db.budget(db.opts.Analyst)
+	if budget.Exhausted {
+		return nil, fmt.Errorf("%w for analyst %q: %d SELECTs have spent ε %.4f of %.4f",
+			ErrBudgetExhausted, budget.Analyst, budget.Queries, budget.Used, budget.Total)
+	}
+	epsilon, delta := budget.Next, budget.DeltaNext
+	entry := ledgerEntry{Analyst: db.opts.Analyst, Time: time.Now().UTC(), Epsilon: epsilon, Delta: delta, SQL: statement.SQL}
+	if err := db.ledger.record(entry); err != nil {
+		return nil, fmt.Errorf("Error writing to budget ledger: %v", err)
+	}
+
+	// add noise with ε_n and δ_n, scaled to the aggregates' sensitivities
+	noise := newNoiser(db.opts.Mechanism, epsilon, delta, sensitivities, db.noise)
+	for _, row := range result.Rows {
+		for _, col := range result.Columns {
+			v, ok := row[col.Name].(float64)
+			if !col.FunctionResult || !ok || sensitivities[col.Name].ct == columnTypeAvg {
+				continue
+			}
+			if _, noised := sensitivities[col.Name]; !noised {
+				// the hidden count, which nothing releases
+				row[col.Name] = nil
+				continue
+			}
+			row[col.Name] = noise(col.Name, v)
+		}
+		// AVGs last, once the counts they are divided by have their noise
+		for _, col := range result.Columns {
+			v, ok := row[col.Name].(float64)
+			sensitivity := sensitivities[col.Name]
+			if !ok || sensitivity.ct != columnTypeAvg {
+				continue
+			}
+			row[col.Name] = noisyAverage(noise(col.Name, v), toFloat64(row[sensitivity.count]), sensitivity.bounds)
+		}
+	}
+
+	// HAVING and ORDER BY only ever see noised aggregates
+	result = applyHaving(result, astNode)
+
+	// order on the noised values, before l-diversity drops any columns
+	result = applyOrderBy(result, astNode)
+
+	// k‑anonymity & l‑diversity, as configured in Options
+	result = enforceKAnonymity(result, db.opts.QuasiIDs, db.opts.K)
+	result = enforceLDiversity(result, db.opts.Sensitive, db.opts.L)
+
+	result = applyLimitOffset(result, astNode)
+
+	return newRows(result, epsilon, delta), nil
+}
+
+// budgetShare is the share of a total budget that the nth SELECT gets:
+// total·(1−r)·r^(n−1), so that all of them together never exceed total.
+func budgetShare(total float64, r float64, n int) float64 {
+	return total * (1.0 - r) * math.Pow(r, float64(n-1))
+}
+
+// deltaBudget is the δ SELECTs share, which is none under Laplace.
+func (db *DB) deltaBudget() float64 {
+	if db.opts.Mechanism != Gaussian {
+		return 0
+	}
+	return db.opts.DeltaBudget
+}
+
+// newRows turns the visible columns of a result table into Rows.
+func newRows(result dbTable, epsilon float64, delta float64) *Rows {
+	visCols, labels := visibleColumns(result)
+	rows := &Rows{Columns: make([]ResultColumn, len(visCols)), Values: make([][]interface{}, len(result.Rows)), Epsilon: epsilon, Delta: delta}
+	for i, col := range visCols {
+		rows.Columns[i] = ResultColumn{Name: labels[i], Type: col.Type}
+	}
+	for ri, row := range result.Rows {
+		values := make([]interface{}, len(visCols))
+		for i, col := range visCols {
+			if v, ok := row[col.Name].(int); ok {
+				values[i] = int64(v)
+			} else {
+				values[i] = row[col.Name]
+			}
+		}
+		rows.Values[ri] = values
+	}
+	return rows
+}
+
+// apply runs a statement that changes the database.
+func (db *DB) apply(astNode *astNode) (Result, error) {
+	switch astNode.Type {
+	case astCreate:
+		return db.createTableFromAST(astNode)
+	case astInsert:
+		return db.insertIntoFromAST(astNode)
+	case astUpdate:
+		return db.updateFromAST(astNode)
+	case astDelete:
+		return db.deleteFromAST(astNode)
+	case astTruncate:
+		return db.truncateFromAST(astNode)
+	case astDrop:
+		return db.dropTableFromAST(astNode)
+	case astAlter:
+		return db.alterTableFromAST(astNode)
+	case astTransaction:
+		return db.transactionFromAST(astNode)
+	}
+	return Result{}, fmt.Errorf("Cannot run AST node type %d", astNode.Type)
+}
+
+// endOpenTransaction rolls back a transaction that was never committed and
+// logs the ROLLBACK, so a later replay ends it at the same point.
+func (db *DB) endOpenTransaction() {
+	db.rollbackTransaction()
+	fmt.Fprintln(db.log, "Rolled back the uncommitted transaction")
+	if err := db.wal.append("ROLLBACK;"); err != nil {
+		fmt.Fprintln(db.log, "Error writing to write-ahead log:", err)
+	}
+}
+
+// replayStatements re-runs logged statements after a crash. A statement that
+// failed the first time fails the same way now, and is skipped.
+func (db *DB) replayStatements(records []walRecord) {
+	for _, record := range records {
+		func() {
+			defer func() { recover() }()
+			astNodes, _ := parseCommands(tokenize(record.sql))
+			for _, astNode := range astNodes {
+				db.apply(astNode)
+			}
+		}()
+	}
+}
+
+// Budget is how much of their privacy budget an analyst's SELECTs have
+// spent, in ε and, under the Gaussian mechanism, δ.
+type Budget struct {
+	Analyst   string
+	Queries   int
+	Total     float64
+	Used      float64
+	Remaining float64
+	// Next is the ε the next SELECT will get.
+	Next float64
+
+	DeltaTotal     float64
+	DeltaUsed      float64
+	DeltaRemaining float64
+	DeltaNext      float64
+
+	// Exhausted is set once the next SELECT would be refused.
+	Exhausted bool
+}
+
+// Budget reports the privacy budget the analyst has spent so far, in this
+// session and earlier ones.
+func (db *DB) Budget() Budget {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	// read the ledger up to date if it can be; if not, what this handle has
+	// counted is the best there is
+	if db.ledger.lock() == nil {
+		defer db.ledger.unlock()
+	}
+	return db.budget(db.opts.Analyst)
+}
+
+// budget works out an analyst's budget from the ledger. The next share goes
+// by how many SELECTs they have run; what they have used is what the ledger
+// recorded, which may have been under other settings.
+func (db *DB) budget(analyst string) Budget {
+	account := db.ledger.account(analyst)
+	r, n := db.opts.DecayRate, account.queries
+	total, deltaTotal := db.opts.EpsilonBudget, db.deltaBudget()
+	b := Budget{
+		Analyst:        analyst,
+		Queries:        n,
+		Total:          total,
+		Used:           account.epsilon,
+		Remaining:      math.Max(total-account.epsilon, 0),
+		Next:           budgetShare(total, r, n+1),
+		DeltaTotal:     deltaTotal,
+		DeltaUsed:      account.delta,
+		DeltaRemaining: math.Max(deltaTotal-account.delta, 0),
+		DeltaNext:      budgetShare(deltaTotal, r, n+1),
+	}
+	// a little slack for the rounding in sums of shares
+	const slack = 1e-9
+	b.Exhausted = b.Next < db.opts.MinEpsilon ||
+		b.Next > b.Remaining*(1+slack) ||
+		b.DeltaNext > b.DeltaRemaining*(1+slack)
+	if b.Exhausted {
+		b.Next, b.DeltaNext = 0, 0
+	}
+	return b
+}
+
+// showBudget is the result of SHOW BUDGET: one row for every analyst in the
+// ledger, and for the current one even before their first SELECT.
+func (db *DB) showBudget() *Rows {
+	analysts := []string{db.opts.Analyst}
+	for analyst := range db.ledger.accounts {
+		if analyst != db.opts.Analyst {
+			analysts = append(analysts, analyst)
+		}
+	}
+	sort.Strings(analysts)
+
+	rows := &Rows{Columns: []ResultColumn{
+		{Name: "analyst", Type: "VARCHAR"},
+		{Name: "queries", Type: "INT"},
+		{Name: "epsilon_used", Type: "FLOAT"},
+		{Name: "epsilon_remaining", Type: "FLOAT"},
+		{Name: "next_epsilon", Type: "FLOAT"},
+		{Name: "delta_used", Type: "FLOAT"},
+		{Name: "delta_remaining", Type: "FLOAT"},
+		{Name: "next_delta", Type: "FLOAT"},
+	}}
+	for _, analyst := range analysts {
+		b := db.budget(analyst)
+		rows.Values = append(rows.Values, []interface{}{
+			b.Analyst, int64(b.Queries), b.Used, b.Remaining, b.Next, b.DeltaUsed, b.DeltaRemaining, b.DeltaNext,
+		})
+	}
+	return rows
+}
+
+// Tables lists the names of the tables, sorted. It leaves out how many rows
+// they hold: that would be an exact COUNT(*) that spends no budget.
+func (db *DB) Tables() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	names := make([]string, 0, len(db.tables))
+	for name := range db.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Schema returns the CREATE TABLE statement for a table.
+func (db *DB) Schema(name string) (string, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	table, ok := db.tables[name]
+	if !ok {
+		return "", fmt.Errorf("Table %s does not exist", name)
+	}
+	return formatCreateTable(table), nil
+}
+
+// formatCreateTable writes a table's schema back out as CREATE TABLE.
+func formatCreateTable(table dbTable) string {
+	lines := make([]string, 0, len(table.Columns)+len(table.ForeignKeys))
+	for _, col := range table.Columns {
+		def := quoteIdentifier(col.Name) + " " + col.Type
+		if strings.ToUpper(col.Type) == "VARCHAR" {
+			def += fmt.Sprintf("(%d)", col.VarCharLimit)
+		}
+		for _, condition := range col.Conditions {
+			if strings.HasPrefix(condition, "DEFAULT ") && strings.ToUpper(col.Type) == "VARCHAR" {
+				condition = "DEFAULT '" + strings.ReplaceAll(strings.TrimPrefix(condition, "DEFAULT "), "'", "''") + "'"
+			}
+			def += " " + condition
+		}
+		lines = append(lines, "    "+def)
+	}
+	for _, fk := range table.ForeignKeys {
+		lines = append(lines, "    "+fk.String())
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", quoteIdentifier(table.Name), strings.Join(lines, ",\n"))
+}
@@ -0,0 +1,458 @@
+package dpsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DriverName is the name the database/sql driver is registered under:
+//
+//	db, err := sql.Open("dpsql", "study.json?epsilon=1&k=5")
+const DriverName = "dpsql"
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// Driver is the database/sql driver. A data source name is the path of the
+// database file, empty or ":memory:" for an in-memory database, optionally
+// followed by settings that override DefaultOptions:
+//
+//	epsilon, decay, delta, k, l      numbers, as in Options
+//	min_epsilon                      a number, as in Options
+//	mechanism, analyst               as in Options
+//	seed                             a number for NewSeededSource
+//	quasi, sensitive                 comma separated column names
+//
+// Every connection of one sql.DB shares a single DB, so they see the same
+// tables and privacy budget. A DB has one transaction at a time, so while a
+// connection has one open, Begin and statements on the other connections
+// wait for it to commit or roll back. Open a file through one sql.DB at a
+// time.
+type Driver struct{}
+
+// Open opens a connection to its own DB. database/sql uses OpenConnector
+// instead, so that connections share one.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector parses dsn into Options.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	opts, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnector(opts), nil
+}
+
+// parseDSN turns "path?key=value&..." into Options.
+func parseDSN(dsn string) (Options, error) {
+	opts := DefaultOptions()
+	path, query, _ := strings.Cut(dsn, "?")
+	if path != ":memory:" {
+		opts.Path = path
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return opts, fmt.Errorf("dpsql: bad data source name %q: %v", dsn, err)
+	}
+	for key := range values {
+		value := values.Get(key)
+		switch key {
+		case "epsilon":
+			opts.EpsilonBudget, err = strconv.ParseFloat(value, 64)
+		case "decay":
+			opts.DecayRate, err = strconv.ParseFloat(value, 64)
+		case "delta":
+			opts.DeltaBudget, err = strconv.ParseFloat(value, 64)
+		case "mechanism":
+			opts.Mechanism = Mechanism(value)
+		case "analyst":
+			opts.Analyst = value
+		case "min_epsilon":
+			opts.MinEpsilon, err = strconv.ParseFloat(value, 64)
+		case "k":
+			opts.K, err = strconv.Atoi(value)
+		case "l":
+			opts.L, err = strconv.Atoi(value)
+		case "seed":
+			var seed int64
+			seed, err = strconv.ParseInt(value, 10, 64)
+			opts.Noise = NewSeededSource(seed)
+		case "quasi":
+			opts.QuasiIDs = SplitNames(value)
+		case "sensitive":
+			opts.Sensitive = SplitNames(value)
+		default:
+			return opts, fmt.Errorf("dpsql: unknown setting %q in data source name", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("dpsql: bad %s %q in data source name", key, value)
+		}
+	}
+	return opts, nil
+}
+
+// Connector hands out connections to one DB, opened on first use and closed
+// with the sql.DB.
+type Connector struct {
+	opts Options
+
+	mu  sync.Mutex
+	db  *DB
+	err error
+
+	// held by the connection with the DB's transaction open, and briefly
+	// by each statement outside it
+	txMu sync.Mutex
+}
+
+// NewConnector returns a connector for sql.OpenDB, for settings a data
+// source name can't carry, such as Options.Log.
+func NewConnector(opts Options) *Connector {
+	return &Connector{opts: opts}
+}
+
+// Connect opens the DB if it isn't yet and returns a connection to it.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil && c.err == nil {
+		c.db, c.err = Open(c.opts)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &conn{db: c.db, connector: c}, nil
+}
+
+// Driver returns the dpsql driver.
+func (c *Connector) Driver() driver.Driver {
+	return &Driver{}
+}
+
+// Close closes the DB; database/sql calls it from sql.DB.Close.
+func (c *Connector) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.db == nil {
+		return nil
+	}
+	err := c.db.Close()
+	c.db = nil
+	c.err = errors.New("dpsql: connector is closed")
+	return err
+}
+
+// conn is one database/sql connection, which database/sql never uses from
+// two goroutines at once.
+type conn struct {
+	db        *DB
+	connector *Connector
+	// the transaction this connection has open, or nil
+	tx *tx
+}
+
+// Prepare checks the statement's syntax, with a 0 standing in for each
+// placeholder.
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	s := &stmt{conn: c, query: query, placeholders: findPlaceholders(query)}
+	zeros := make([]driver.Value, len(s.placeholders))
+	for i := range zeros {
+		zeros[i] = int64(0)
+	}
+	bound, _ := s.bind(zeros)
+	statements, parseErrors := Parse(bound)
+	if len(parseErrors) > 0 {
+		return nil, parseErrors[0]
+	}
+	if len(statements) == 0 {
+		return nil, errors.New("dpsql: no statement to prepare")
+	}
+	// the transaction belongs to whoever called Begin, so SQL can't start
+	// or end it behind database/sql's back
+	for _, statement := range statements {
+		switch statement.node.transactionAction {
+		case "BEGIN", "COMMIT", "ROLLBACK":
+			return nil, fmt.Errorf("dpsql: use the database/sql transaction methods instead of %s", statement.node.transactionAction)
+		}
+	}
+	return s, nil
+}
+
+// Close rolls back a transaction the connection left open.
+func (c *conn) Close() error {
+	if c.tx != nil {
+		return c.tx.Rollback()
+	}
+	return nil
+}
+
+// Begin starts the DB's transaction for this connection, waiting while
+// another connection has it.
+func (c *conn) Begin() (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.New("dpsql: connection already has a transaction open")
+	}
+	c.connector.txMu.Lock()
+	if _, err := c.db.Exec("BEGIN;"); err != nil {
+		c.connector.txMu.Unlock()
+		return nil, err
+	}
+	c.tx = &tx{conn: c}
+	return c.tx, nil
+}
+
+// run calls f with the DB to itself: inside this connection's transaction,
+// or else once no other connection has one open.
+func (c *conn) run(f func() error) error {
+	if c.tx == nil {
+		c.connector.txMu.Lock()
+		defer c.connector.txMu.Unlock()
+	}
+	return f()
+}
+
+type tx struct {
+	conn *conn
+}
+
+func (t *tx) Commit() error {
+	return t.end("COMMIT;")
+}
+
+func (t *tx) Rollback() error {
+	return t.end("ROLLBACK;")
+}
+
+// end commits or rolls back, and hands the DB on to the other connections
+// either way: these only fail once the log can't be written, and from then
+// on the DB refuses every change.
+func (t *tx) end(statement string) error {
+	c := t.conn
+	if c.tx != t {
+		return errors.New("dpsql: transaction has already ended")
+	}
+	_, err := c.db.Exec(statement)
+	c.tx = nil
+	c.connector.txMu.Unlock()
+	return err
+}
+
+// stmt is a prepared statement: its text and where its ? placeholders are.
+// Arguments are written into the text as SQL literals when it runs.
+type stmt struct {
+	conn         *conn
+	query        string
+	placeholders []int
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return len(s.placeholders)
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	query, err := s.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	var result Result
+	err = s.conn.run(func() (err error) {
+		result, err = s.conn.db.Exec(query)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return execResult{result}, nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	query, err := s.bind(args)
+	if err != nil {
+		return nil, err
+	}
+	var result *Rows
+	err = s.conn.run(func() (err error) {
+		result, err = s.conn.db.Query(query)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rows{rows: result}, nil
+}
+
+// bind replaces each placeholder with its argument.
+func (s *stmt) bind(args []driver.Value) (string, error) {
+	if len(args) != len(s.placeholders) {
+		return "", fmt.Errorf("dpsql: expected %d arguments, got %d", len(s.placeholders), len(args))
+	}
+	var b strings.Builder
+	last := 0
+	for i, at := range s.placeholders {
+		literal, err := sqlLiteral(args[i])
+		if err != nil {
+			return "", fmt.Errorf("dpsql: argument %d: %v", i+1, err)
+		}
+		b.WriteString(s.query[last:at])
+		// "x -?" with -1 must not turn into the comment "x --1"
+		if at > 0 && s.query[at-1] == '-' && strings.HasPrefix(literal, "-") {
+			b.WriteString(" ")
+		}
+		b.WriteString(literal)
+		last = at + 1
+	}
+	b.WriteString(s.query[last:])
+	return b.String(), nil
+}
+
+// findPlaceholders returns the offsets of the ? placeholders in query,
+// skipping string literals, quoted identifiers and comments.
+func findPlaceholders(query string) []int {
+	var placeholders []int
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// a doubled quote inside is just two quoted runs in a row
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				return placeholders
+			}
+			i += 1 + end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return placeholders
+			}
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return placeholders
+			}
+			i += 2 + end + 1
+		case c == '?':
+			placeholders = append(placeholders, i)
+		}
+	}
+	return placeholders
+}
+
+// sqlLiteral writes a driver value as SQL that the lexer reads back as the
+// same value.
+func sqlLiteral(value driver.Value) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("%v has no SQL literal", v)
+		}
+		literal := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(literal, ".e") {
+			literal += ".0" // keep it a FLOAT literal
+		}
+		return literal, nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	case []byte:
+		return "'" + strings.ReplaceAll(string(v), "'", "''") + "'", nil
+	case time.Time:
+		return "'" + v.Format(time.RFC3339Nano) + "'", nil
+	default:
+		return "", fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// execResult is what Exec did. The engine has no row IDs.
+type execResult struct {
+	result Result
+}
+
+func (r execResult) LastInsertId() (int64, error) {
+	return 0, errors.New("dpsql: LastInsertId is not supported")
+}
+
+func (r execResult) RowsAffected() (int64, error) {
+	return r.result.RowsAffected, nil
+}
+
+// rows walks a query result for database/sql.
+type rows struct {
+	rows *Rows
+	next int
+}
+
+func (r *rows) Columns() []string {
+	names := make([]string, len(r.rows.Columns))
+	for i, col := range r.rows.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows.Values) {
+		return io.EOF
+	}
+	for i, v := range r.rows.Values[r.next] {
+		dest[i] = v
+	}
+	r.next++
+	return nil
+}
+
+// ColumnTypeDatabaseTypeName is the column's SQL type: INT, FLOAT or VARCHAR.
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	return strings.ToUpper(r.rows.Columns[index].Type)
+}
+
+// ColumnTypeScanType is the Go type values of the column come back as.
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	switch r.ColumnTypeDatabaseTypeName(index) {
+	case "INT":
+		return reflect.TypeOf(int64(0))
+	case "FLOAT":
+		return reflect.TypeOf(float64(0))
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+// ColumnTypeNullable reports that any column can hold NULL.
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	return true, true
+}
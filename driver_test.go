@@ -0,0 +1,150 @@
+package dpsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"SELECT a FROM t WHERE a = ?", []int{26}},
+		{"VALUES (?, ?)", []int{8, 11}},
+		{"WHERE a = '?' AND b = ?", []int{22}},
+		{`WHERE "a?" = ?`, []int{13}},
+		{"WHERE a = 'it''s ?' AND b = ?", []int{28}},
+		{"-- what?\nWHERE a = ?", []int{19}},
+		{"/* ? */ WHERE a = ?", []int{18}},
+		{"WHERE a = '?", nil},
+	}
+	for _, test := range tests {
+		if got := findPlaceholders(test.query); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("findPlaceholders(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestStmtBind(t *testing.T) {
+	when := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		args  []driver.Value
+		want  string
+	}{
+		{"VALUES (?, ?, ?)", []driver.Value{int64(-3), nil, true}, "VALUES (-3, NULL, TRUE)"},
+		{"VALUES (?, ?)", []driver.Value{float64(3), 2.5}, "VALUES (3.0, 2.5)"},
+		{"VALUES (?)", []driver.Value{1e300}, "VALUES (1e+300)"},
+		{"WHERE name = ?", []driver.Value{"O'Brien"}, "WHERE name = 'O''Brien'"},
+		{"WHERE name = ?", []driver.Value{[]byte("x")}, "WHERE name = 'x'"},
+		{"WHERE at = ?", []driver.Value{when}, "WHERE at = '2025-03-01T12:30:00Z'"},
+		// "x -?" with -1 must not become the comment "x --1"
+		{"WHERE a = 5 -?", []driver.Value{int64(-1)}, "WHERE a = 5 - -1"},
+		{"WHERE a = '?' AND b = ?", []driver.Value{int64(1)}, "WHERE a = '?' AND b = 1"},
+	}
+	for _, test := range tests {
+		s := &stmt{query: test.query, placeholders: findPlaceholders(test.query)}
+		got, err := s.bind(test.args)
+		if err != nil {
+			t.Errorf("bind(%q, %v): %v", test.query, test.args, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("bind(%q, %v) = %q, want %q", test.query, test.args, got, test.want)
+		}
+	}
+
+	s := &stmt{query: "VALUES (?, ?)", placeholders: findPlaceholders("VALUES (?, ?)")}
+	if _, err := s.bind([]driver.Value{int64(1)}); err == nil {
+		t.Error("bind with too few arguments succeeded")
+	}
+	for _, bad := range []driver.Value{math.NaN(), struct{}{}} {
+		s := &stmt{query: "VALUES (?)", placeholders: []int{8}}
+		if _, err := s.bind([]driver.Value{bad}); err == nil {
+			t.Errorf("bind(%v) succeeded", bad)
+		}
+	}
+}
+
+func TestDriverPlaceholders(t *testing.T) {
+	db, err := sql.Open(DriverName, ":memory:?k=0&seed=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE people (name VARCHAR(20), age INT BOUNDS(0, 120));"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []struct {
+		name string
+		age  int
+	}{{"Ann", 30}, {"O'Neil", 40}, {"-- not a comment", 50}} {
+		if _, err := db.Exec("INSERT INTO people (name, age) VALUES (?, ?);", p.name, p.age); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var name string
+	var count float64
+	err = db.QueryRow("SELECT name, COUNT(age) FROM people WHERE name = ? GROUP BY name;", "O'Neil").Scan(&name, &count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "O'Neil" {
+		t.Errorf("name = %q, want O'Neil", name)
+	}
+
+	if _, err := db.Exec("BEGIN;"); err == nil {
+		t.Error("BEGIN as a statement was accepted")
+	}
+}
+
+func TestDriverTransactionHoldsOtherConnections(t *testing.T) {
+	// a budget big enough that the noise can't hide which rows are left
+	db, err := sql.Open(DriverName, ":memory:?k=0&seed=1&epsilon=100000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (x INT BOUNDS(0, 10));"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO t (x) VALUES (1);"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := db.Exec("INSERT INTO t (x) VALUES (2);")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("another connection ran inside the transaction: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// only the other connection's row is left
+	var count, largest float64
+	if err := db.QueryRow("SELECT COUNT(x), MAX(x) FROM t;").Scan(&count, &largest); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(count-1) > 0.1 || math.Abs(largest-2) > 0.1 {
+		t.Errorf("COUNT(x), MAX(x) = %v, %v, want about 1 and 2", count, largest)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package dpsql
+
+import "os"
+
+// Without flock nothing stops two processes opening one database, and the
+// ledger is only safe for one process at a time.
+
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func tryLockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package dpsql
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// errFileLocked is returned by tryLockFile when another handle holds the
+// lock.
+var errFileLocked = errors.New("file is locked")
+
+// lockFile waits for an exclusive lock on the whole file.
+func lockFile(file *os.File) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// tryLockFile takes an exclusive lock on the whole file, or returns
+// errFileLocked at once if it is held.
+func tryLockFile(file *os.File) error {
+	for {
+		err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			return errFileLocked
+		}
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,368 @@
+package dpsql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// foreignKey ties Columns of a table to RefColumns of RefTable. OnDelete is
+// what happens to referencing rows when the referenced row is deleted:
+// "RESTRICT" (the default), "CASCADE" or "SET NULL".
+type foreignKey struct {
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+}
+
+func (fk foreignKey) String() string {
+	quoteAll := func(names []string) string {
+		quoted := make([]string, len(names))
+		for i, name := range names {
+			quoted[i] = quoteIdentifier(name)
+		}
+		return strings.Join(quoted, ", ")
+	}
+	return fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
+		quoteAll(fk.Columns), quoteIdentifier(fk.RefTable), quoteAll(fk.RefColumns), fk.OnDelete)
+}
+
+// checkForeignKeyDefinitions validates the foreign keys of a table that is
+// being created (or altered) with the given columns. A table may reference
+// itself, in which case its own columns are checked.
+func (db *DB) checkForeignKeyDefinitions(tableName string, columns []dbColumn, fks []foreignKey) error {
+	hasColumn := func(cols []dbColumn, name string) (dbColumn, bool) {
+		for _, col := range cols {
+			if col.Name == name {
+				return col, true
+			}
+		}
+		return dbColumn{}, false
+	}
+
+	for _, fk := range fks {
+		if len(fk.Columns) != len(fk.RefColumns) {
+			return fmt.Errorf("%s: column count doesn't match the referenced columns", fk)
+		}
+		refColumns := columns
+		if fk.RefTable != tableName {
+			if !db.tableExists(fk.RefTable) {
+				return fmt.Errorf("%s: table %s does not exist", fk, fk.RefTable)
+			}
+			refColumns = db.tables[fk.RefTable].Columns
+		}
+		for i, name := range fk.Columns {
+			if _, ok := hasColumn(columns, name); !ok {
+				return fmt.Errorf("%s: column %s does not exist in %s", fk, name, tableName)
+			}
+			refCol, ok := hasColumn(refColumns, fk.RefColumns[i])
+			if !ok {
+				return fmt.Errorf("%s: column %s does not exist in %s", fk, fk.RefColumns[i], fk.RefTable)
+			}
+			// a single referenced column must identify one row
+			if len(fk.RefColumns) == 1 && !hasCondition(refCol, "PRIMARY KEY") && !hasCondition(refCol, "UNIQUE") {
+				return fmt.Errorf("%s: %s.%s must be PRIMARY KEY or UNIQUE", fk, fk.RefTable, refCol.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// referencesRow reports whether child's foreign key columns point at parent.
+// Rows with a NULL in the key don't reference anything.
+func referencesRow(fk foreignKey, child map[string]interface{}, parent map[string]interface{}) bool {
+	for i, name := range fk.Columns {
+		cmp, ok := compareValues(child[name], parent[fk.RefColumns[i]])
+		if !ok || cmp != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func hasNullKey(fk foreignKey, row map[string]interface{}) bool {
+	for _, name := range fk.Columns {
+		if row[name] == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkForeignKeys makes sure every foreign key of a row being written points
+// at an existing row. ownRows are the table's rows as they will be after the
+// write, used for self-referencing keys.
+func (db *DB) checkForeignKeys(table dbTable, row map[string]interface{}, ownRows []map[string]interface{}) error {
+	for _, fk := range table.ForeignKeys {
+		if hasNullKey(fk, row) {
+			continue
+		}
+		parents := ownRows
+		if fk.RefTable != table.Name {
+			parents = db.tables[fk.RefTable].Rows
+		}
+		found := false
+		for _, parent := range parents {
+			if referencesRow(fk, row, parent) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values := make([]string, len(fk.Columns))
+			for i, name := range fk.Columns {
+				values[i] = fmt.Sprint(row[name])
+			}
+			return fmt.Errorf("Column %s: no row in %s has %s = %s", strings.Join(fk.Columns, ", "),
+				fk.RefTable, strings.Join(fk.RefColumns, ", "), strings.Join(values, ", "))
+		}
+	}
+	return nil
+}
+
+// referencingKeys lists every foreign key in the database pointing at
+// tableName, keyed by the referencing table.
+func (db *DB) referencingKeys(tableName string) map[string][]foreignKey {
+	refs := make(map[string][]foreignKey)
+	for childName, child := range db.tables {
+		for _, fk := range child.ForeignKeys {
+			if fk.RefTable == tableName {
+				refs[childName] = append(refs[childName], fk)
+			}
+		}
+	}
+	return refs
+}
+
+// referencedBy returns another table with a foreign key pointing at
+// tableName, which blocks DROP TABLE and TRUNCATE.
+func (db *DB) referencedBy(tableName string) (string, bool) {
+	for childName := range db.referencingKeys(tableName) {
+		if childName != tableName {
+			return childName, true
+		}
+	}
+	return "", false
+}
+
+// checkKeyChanges refuses an UPDATE that changes a referenced value while
+// rows elsewhere still point at the old one.
+func (db *DB) checkKeyChanges(tableName string, oldRow map[string]interface{}, newRow map[string]interface{}) error {
+	for childName, fks := range db.referencingKeys(tableName) {
+		for _, fk := range fks {
+			changed := false
+			for _, name := range fk.RefColumns {
+				if cmp, ok := compareValues(oldRow[name], newRow[name]); !ok || cmp != 0 {
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			for _, child := range db.tables[childName].Rows {
+				if referencesRow(fk, child, oldRow) {
+					return fmt.Errorf("Cannot change %s.%s: still referenced by %s", tableName,
+						strings.Join(fk.RefColumns, ", "), childName)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deletePlan is what a DELETE does to the database once ON DELETE actions
+// have been followed: the rows each table loses and the rows whose foreign
+// key columns are set to NULL.
+type deletePlan struct {
+	doomed map[string]map[int]bool
+	nulled map[string]map[int][]string
+	order  []string // tables in the order they were first touched
+}
+
+// planDelete works out the effect of deleting rows rowIndexes of tableName,
+// cascading through referencing tables. It fails if a RESTRICT reference
+// (or SET NULL on a NOT NULL column) would be left dangling.
+func (db *DB) planDelete(tableName string, rowIndexes []int) (*deletePlan, error) {
+	plan := &deletePlan{
+		doomed: make(map[string]map[int]bool),
+		nulled: make(map[string]map[int][]string),
+	}
+	type pending struct {
+		table string
+		rows  []int
+	}
+	type restriction struct {
+		child  string
+		row    int
+		parent string
+	}
+	var restricted []restriction
+
+	queue := []pending{{tableName, rowIndexes}}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		if plan.doomed[next.table] == nil {
+			plan.doomed[next.table] = make(map[int]bool)
+			plan.order = append(plan.order, next.table)
+		}
+		parents := make([]map[string]interface{}, 0, len(next.rows))
+		for _, ri := range next.rows {
+			if !plan.doomed[next.table][ri] {
+				plan.doomed[next.table][ri] = true
+				parents = append(parents, db.tables[next.table].Rows[ri])
+			}
+		}
+		if len(parents) == 0 {
+			continue
+		}
+
+		for childName, fks := range db.referencingKeys(next.table) {
+			child := db.tables[childName]
+			for _, fk := range fks {
+				cascade := make([]int, 0)
+				for ci, childRow := range child.Rows {
+					if plan.doomed[childName][ci] {
+						continue
+					}
+					referenced := false
+					for _, parent := range parents {
+						if referencesRow(fk, childRow, parent) {
+							referenced = true
+							break
+						}
+					}
+					if !referenced {
+						continue
+					}
+					switch fk.OnDelete {
+					case "CASCADE":
+						cascade = append(cascade, ci)
+					case "SET NULL":
+						for _, name := range fk.Columns {
+							for _, col := range child.Columns {
+								if col.Name == name && (hasCondition(col, "NOT NULL") || hasCondition(col, "PRIMARY KEY")) {
+									return nil, fmt.Errorf("Cannot delete from %s: ON DELETE SET NULL on %s.%s, which is NOT NULL",
+										next.table, childName, name)
+								}
+							}
+						}
+						if plan.nulled[childName] == nil {
+							plan.nulled[childName] = make(map[int][]string)
+						}
+						plan.nulled[childName][ci] = append(plan.nulled[childName][ci], fk.Columns...)
+					default:
+						restricted = append(restricted, restriction{childName, ci, next.table})
+					}
+				}
+				if len(cascade) > 0 {
+					queue = append(queue, pending{childName, cascade})
+				}
+			}
+		}
+	}
+
+	// a RESTRICT reference is fine if its row goes away through a cascade
+	for _, r := range restricted {
+		if !plan.doomed[r.child][r.row] {
+			return nil, fmt.Errorf("Cannot delete from %s: rows in %s still reference it", r.parent, r.child)
+		}
+	}
+	return plan, nil
+}
+
+// apply carries out a delete plan and returns how many rows each table lost.
+func (plan *deletePlan) apply(db *DB) map[string]int {
+	deleted := make(map[string]int)
+	touched := append([]string{}, plan.order...)
+	for name := range plan.nulled {
+		if plan.doomed[name] == nil {
+			touched = append(touched, name)
+		}
+	}
+
+	for _, name := range touched {
+		table := db.tables[name]
+		kept := make([]map[string]interface{}, 0, len(table.Rows))
+		for ri, row := range table.Rows {
+			if plan.doomed[name][ri] {
+				continue
+			}
+			if columns, ok := plan.nulled[name][ri]; ok {
+				newRow := make(map[string]interface{}, len(row))
+				for k, v := range row {
+					newRow[k] = v
+				}
+				for _, col := range columns {
+					newRow[col] = nil
+				}
+				row = newRow
+			}
+			kept = append(kept, row)
+		}
+		deleted[name] = len(table.Rows) - len(kept)
+		table.Rows = kept
+		db.tables[name] = table
+	}
+	return deleted
+}
+
+// renameForeignKeyColumn keeps foreign key definitions pointing at a column
+// after ALTER TABLE ... RENAME COLUMN.
+func (db *DB) renameForeignKeyColumn(tableName string, oldName string, newName string) {
+	for name, table := range db.tables {
+		for i, fk := range table.ForeignKeys {
+			if name == tableName {
+				for j, col := range fk.Columns {
+					if col == oldName {
+						table.ForeignKeys[i].Columns[j] = newName
+					}
+				}
+			}
+			if fk.RefTable == tableName {
+				for j, col := range fk.RefColumns {
+					if col == oldName {
+						table.ForeignKeys[i].RefColumns[j] = newName
+					}
+				}
+			}
+		}
+	}
+}
+
+// renameForeignKeyTable keeps foreign keys pointing at a table after ALTER
+// TABLE ... RENAME TO.
+func (db *DB) renameForeignKeyTable(oldName string, newName string) {
+	for _, table := range db.tables {
+		for i, fk := range table.ForeignKeys {
+			if fk.RefTable == oldName {
+				table.ForeignKeys[i].RefTable = newName
+			}
+		}
+	}
+}
+
+// foreignKeyUsesColumn reports which foreign key, if any, involves a column
+// (on either side), so ALTER TABLE ... DROP COLUMN can refuse.
+func (db *DB) foreignKeyUsesColumn(tableName string, columnName string) (foreignKey, bool) {
+	for name, table := range db.tables {
+		for _, fk := range table.ForeignKeys {
+			if name == tableName {
+				for _, col := range fk.Columns {
+					if col == columnName {
+						return fk, true
+					}
+				}
+			}
+			if fk.RefTable == tableName {
+				for _, col := range fk.RefColumns {
+					if col == columnName {
+						return fk, true
+					}
+				}
+			}
+		}
+	}
+	return foreignKey{}, false
+}
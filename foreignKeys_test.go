@@ -0,0 +1,142 @@
+package dpsql
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// familySchema is a parent p, children c that go with it, and grandchildren
+// g that let go of their c.
+const familySchema = `CREATE TABLE p (id INT PRIMARY KEY);
+CREATE TABLE c (id INT PRIMARY KEY, p INT REFERENCES p(id) ON DELETE CASCADE);
+CREATE TABLE g (id INT, c INT REFERENCES c(id) ON DELETE SET NULL);
+INSERT INTO p (id) VALUES (1); INSERT INTO p (id) VALUES (2);
+INSERT INTO c (id, p) VALUES (10, 1); INSERT INTO c (id, p) VALUES (11, 1); INSERT INTO c (id, p) VALUES (12, 2);
+INSERT INTO g (id, c) VALUES (100, 10); INSERT INTO g (id, c) VALUES (101, 12); INSERT INTO g (id, c) VALUES (102, NULL);`
+
+// planRows lists the row indexes a plan deletes and sets to NULL in each
+// table, sorted.
+func planRows(plan *deletePlan) (doomed map[string][]int, nulled map[string][]int) {
+	doomed, nulled = make(map[string][]int), make(map[string][]int)
+	for table, rows := range plan.doomed {
+		for ri := range rows {
+			doomed[table] = append(doomed[table], ri)
+		}
+		sort.Ints(doomed[table])
+	}
+	for table, rows := range plan.nulled {
+		for ri := range rows {
+			nulled[table] = append(nulled[table], ri)
+		}
+		sort.Ints(nulled[table])
+	}
+	return doomed, nulled
+}
+
+func TestPlanDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		extra  string
+		table  string
+		rows   []int
+		doomed map[string][]int
+		nulled map[string][]int
+		fails  bool
+	}{
+		{name: "cascade then set null", table: "p", rows: []int{0},
+			doomed: map[string][]int{"p": {0}, "c": {0, 1}}, nulled: map[string][]int{"g": {0}}},
+		{name: "other parent", table: "p", rows: []int{1},
+			doomed: map[string][]int{"p": {1}, "c": {2}}, nulled: map[string][]int{"g": {1}}},
+		{name: "set null only", table: "c", rows: []int{2},
+			doomed: map[string][]int{"c": {2}}, nulled: map[string][]int{"g": {1}}},
+		{name: "nothing references it", table: "g", rows: []int{0, 2},
+			doomed: map[string][]int{"g": {0, 2}}, nulled: map[string][]int{}},
+		{name: "restrict",
+			extra: "CREATE TABLE r (p INT REFERENCES p(id)); INSERT INTO r (p) VALUES (2);",
+			table: "p", rows: []int{1}, fails: true},
+		{name: "restrict on another row",
+			extra: "CREATE TABLE r (p INT REFERENCES p(id) ON DELETE RESTRICT); INSERT INTO r (p) VALUES (2);",
+			table: "p", rows: []int{0},
+			doomed: map[string][]int{"p": {0}, "c": {0, 1}}, nulled: map[string][]int{"g": {0}}},
+		{name: "restrict on a row that cascades away",
+			extra: "CREATE TABLE y (p INT REFERENCES p(id) ON DELETE CASCADE, c INT REFERENCES c(id)); INSERT INTO y (p, c) VALUES (1, 10);",
+			table: "p", rows: []int{0},
+			doomed: map[string][]int{"p": {0}, "c": {0, 1}, "y": {0}}, nulled: map[string][]int{"g": {0}}},
+		{name: "restrict on a row that stays",
+			extra: "CREATE TABLE y (p INT REFERENCES p(id) ON DELETE CASCADE, c INT REFERENCES c(id)); INSERT INTO y (p, c) VALUES (2, 10);",
+			table: "p", rows: []int{0}, fails: true},
+		{name: "set null on NOT NULL",
+			extra: "CREATE TABLE n (c INT NOT NULL REFERENCES c(id) ON DELETE SET NULL); INSERT INTO n (c) VALUES (11);",
+			table: "p", rows: []int{0}, fails: true},
+		{name: "self reference",
+			extra: `CREATE TABLE e (id INT PRIMARY KEY, boss INT REFERENCES e(id) ON DELETE CASCADE);
+INSERT INTO e (id, boss) VALUES (1, NULL); INSERT INTO e (id, boss) VALUES (2, 1);
+INSERT INTO e (id, boss) VALUES (3, 2); INSERT INTO e (id, boss) VALUES (4, NULL);`,
+			table: "e", rows: []int{0},
+			doomed: map[string][]int{"e": {0, 1, 2}}, nulled: map[string][]int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := Open(DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if _, err := db.Exec(familySchema + test.extra); err != nil {
+				t.Fatal(err)
+			}
+			plan, err := db.planDelete(test.table, test.rows)
+			if (err != nil) != test.fails {
+				t.Fatalf("error %v, want failure = %v", err, test.fails)
+			}
+			if err != nil {
+				return
+			}
+			doomed, nulled := planRows(plan)
+			if !reflect.DeepEqual(doomed, test.doomed) {
+				t.Errorf("deleted rows %v, want %v", doomed, test.doomed)
+			}
+			if !reflect.DeepEqual(nulled, test.nulled) {
+				t.Errorf("nulled rows %v, want %v", nulled, test.nulled)
+			}
+		})
+	}
+}
+
+func TestDeleteFollowsForeignKeys(t *testing.T) {
+	db, err := Open(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(familySchema + "CREATE TABLE r (p INT REFERENCES p(id)); INSERT INTO r (p) VALUES (2);"); err != nil {
+		t.Fatal(err)
+	}
+
+	// r still points at 2, so nothing at all is deleted
+	if _, err := db.Exec("DELETE FROM p WHERE id = 2;"); err == nil {
+		t.Fatal("deleted a row a RESTRICT key references")
+	}
+	if got := columnValues(db, "c", "id") + columnValues(db, "g", "c"); got != "[10 11 12][10 12 <nil>]" {
+		t.Errorf("after the refused DELETE, c.id and g.c are %s", got)
+	}
+
+	result, err := db.Exec("DELETE FROM p WHERE id = 1;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RowsAffected != 1 {
+		t.Errorf("RowsAffected = %d, want the 1 row of p", result.RowsAffected)
+	}
+	for _, check := range []struct{ table, column, want string }{
+		{"p", "id", "[2]"},
+		{"c", "id", "[12]"},
+		{"g", "id", "[100 101 102]"},
+		{"g", "c", "[<nil> 12 <nil>]"},
+	} {
+		if got := columnValues(db, check.table, check.column); got != check.want {
+			t.Errorf("%s.%s = %s, want %s", check.table, check.column, got, check.want)
+		}
+	}
+}
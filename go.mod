@@ -1,3 +1,3 @@
-module main
+module github.com/NividhSingh/sql-db
 
 go 1.23.5
@@ -1,9 +1,20 @@
-package main
+package dpsql
 
-import "strconv"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
-// Helper for MAX function
+// Helper for MAX function. A NULL on either side is skipped, so NULL rows
+// never decide MIN or MAX and an all-NULL group stays NULL.
 func max(a, b interface{}) interface{} {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
 	af := toFloat64(a)
 	bf := toFloat64(b)
 	if af > bf {
@@ -12,8 +23,14 @@ func max(a, b interface{}) interface{} {
 	return b
 }
 
-// Helper for MIN function
+// Helper for MIN function; it skips NULLs like max.
 func min(a, b interface{}) interface{} {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
 	af := toFloat64(a)
 	bf := toFloat64(b)
 	if af < bf {
@@ -37,3 +54,46 @@ func toFloat64(v interface{}) float64 {
 		return 0
 	}
 }
+
+func isNumeric(v interface{}) bool {
+	switch v.(type) {
+	case int, int64, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isTruthy turns a cell or expression value into a boolean for WHERE.
+func isTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	default:
+		return toFloat64(val) != 0
+	}
+}
+
+// compareValues orders two values: numbers compare numerically, everything
+// else compares as text. ok is false when either side is NULL.
+func compareValues(a, b interface{}) (cmp int, ok bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if isNumeric(a) && isNumeric(b) {
+		af, bf := toFloat64(a), toFloat64(b)
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b)), true
+}
@@ -0,0 +1,197 @@
+package dpsql
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ledgerPathFor is the budget ledger that sits next to the database file at
+// path: database.json keeps its ledger in database.ledger.
+func ledgerPathFor(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".ledger"
+}
+
+// minQueryEpsilon is the smallest ε a SELECT may get. The decayed shares of
+// the budget never quite run out, but past this the noise buries the answer,
+// so an analyst whose next share is smaller has exhausted their budget.
+const minQueryEpsilon = 0.01
+
+// ErrBudgetExhausted is returned (wrapped) for a SELECT by an analyst who
+// has no privacy budget left.
+var ErrBudgetExhausted = errors.New("privacy budget exhausted")
+
+// A ledger file has one JSON object per line, appended and synced for every
+// SELECT before its result is returned:
+//
+//	{"analyst":"alice","time":"...","epsilon":5,"delta":0,"sql":"SELECT ..."}
+//
+// Open only lets one handle at a time have a database, and with it its
+// ledger, but the ledger is still locked while a SELECT is charged and read
+// up to date before a budget is checked, so two handles on one ledger never
+// hand out the same budget twice. A last line without its newline is where a crash cut the file
+// short and is dropped; any other line that doesn't parse means the ledger
+// is damaged, and it isn't opened rather than lose what it records.
+
+// ledgerEntry is one SELECT's spending.
+type ledgerEntry struct {
+	Analyst string    `json:"analyst"`
+	Time    time.Time `json:"time"`
+	Epsilon float64   `json:"epsilon"`
+	Delta   float64   `json:"delta"`
+	SQL     string    `json:"sql"`
+}
+
+// ledgerAccount is what one analyst has spent.
+type ledgerAccount struct {
+	queries int
+	epsilon float64
+	delta   float64
+}
+
+// ledger tracks every analyst's spending, on disk unless the database is
+// in memory.
+type ledger struct {
+	file *os.File
+	// bytes of whole entries in file
+	size     int64
+	accounts map[string]*ledgerAccount
+	log      io.Writer
+}
+
+// openLedger opens (or creates) the ledger at path and totals it up. An
+// empty path keeps the ledger in memory.
+func openLedger(path string, log io.Writer) (*ledger, error) {
+	l := &ledger{accounts: make(map[string]*ledgerAccount), log: log}
+	if path == "" {
+		return l, nil
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	if err := l.lock(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := l.unlock(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// lock takes the ledger for this process and catches up on the entries
+// other processes appended since it last looked. It holds until unlock.
+func (l *ledger) lock() error {
+	if l.file == nil {
+		return nil
+	}
+	if err := lockFile(l.file); err != nil {
+		return err
+	}
+	if err := l.catchUp(); err != nil {
+		unlockFile(l.file)
+		return err
+	}
+	return nil
+}
+
+func (l *ledger) unlock() error {
+	if l.file == nil {
+		return nil
+	}
+	return unlockFile(l.file)
+}
+
+// catchUp reads the entries after the ones already counted and leaves the
+// file where the next entry goes. It must hold the lock.
+func (l *ledger) catchUp() error {
+	if _, err := l.file.Seek(l.size, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(l.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// a writer died partway through this entry, and it was
+				// never counted
+				fmt.Fprintf(l.log, "Ignoring the end of %s: incomplete entry after %d bytes\n", l.file.Name(), l.size)
+				if err := l.file.Truncate(l.size); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var entry ledgerEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("%s is damaged: bad entry after %d bytes: %v", l.file.Name(), l.size, err)
+		}
+		l.size += int64(len(line))
+		l.add(entry)
+	}
+	_, err := l.file.Seek(l.size, io.SeekStart)
+	return err
+}
+
+// account returns what analyst has spent so far.
+func (l *ledger) account(analyst string) ledgerAccount {
+	if account, ok := l.accounts[analyst]; ok {
+		return *account
+	}
+	return ledgerAccount{}
+}
+
+func (l *ledger) add(entry ledgerEntry) {
+	account, ok := l.accounts[entry.Analyst]
+	if !ok {
+		account = &ledgerAccount{}
+		l.accounts[entry.Analyst] = account
+	}
+	account.queries++
+	account.epsilon += entry.Epsilon
+	account.delta += entry.Delta
+}
+
+// record writes an entry to disk and counts it. The ledger must be locked.
+func (l *ledger) record(entry ledgerEntry) error {
+	if l.file != nil {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if _, err := l.file.Write(append(line, '\n')); err != nil {
+			// don't leave half a line for the next entry to follow
+			l.file.Truncate(l.size)
+			l.file.Seek(l.size, io.SeekStart)
+			return err
+		}
+		// once it's written, count it even if the sync fails: better to
+		// overcharge than to hand out budget that may already be spent
+		l.size += int64(len(line)) + 1
+		l.add(entry)
+		return l.file.Sync()
+	}
+	l.add(entry)
+	return nil
+}
+
+func (l *ledger) close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
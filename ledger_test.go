@@ -0,0 +1,161 @@
+package dpsql
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLedgerReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.ledger")
+	l, err := openLedger(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []ledgerEntry{
+		{Analyst: "alice", Epsilon: 5, SQL: "SELECT COUNT(x) FROM t;"},
+		{Analyst: "bob", Epsilon: 5, Delta: 5e-6, SQL: "SELECT SUM(x) FROM t;"},
+		{Analyst: "alice", Epsilon: 2.5, SQL: "SELECT AVG(x) FROM t;"},
+	}
+	for _, entry := range entries {
+		entry.Time = time.Now().UTC()
+		if err := l.lock(); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.record(entry); err != nil {
+			t.Fatal(err)
+		}
+		l.unlock()
+	}
+	l.close()
+
+	l, err = openLedger(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+	if got := l.account("alice"); got != (ledgerAccount{queries: 2, epsilon: 7.5}) {
+		t.Errorf("alice = %+v, want 2 queries and ε 7.5", got)
+	}
+	if got := l.account("bob"); got != (ledgerAccount{queries: 1, epsilon: 5, delta: 5e-6}) {
+		t.Errorf("bob = %+v, want 1 query, ε 5 and δ 5e-6", got)
+	}
+	if got := l.account("carol"); got != (ledgerAccount{}) {
+		t.Errorf("carol = %+v, want nothing spent", got)
+	}
+}
+
+func TestLedgerDropsTornLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.ledger")
+	good := `{"analyst":"alice","time":"2025-01-01T00:00:00Z","epsilon":5,"delta":0,"sql":"SELECT 1"}` + "\n"
+	if err := os.WriteFile(path, []byte(good+`{"analyst":"alice","epsi`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var notes strings.Builder
+	l, err := openLedger(path, &notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.account("alice"); got.queries != 1 || got.epsilon != 5 {
+		t.Errorf("alice = %+v, want the one whole entry", got)
+	}
+	if !strings.Contains(notes.String(), "incomplete entry") {
+		t.Errorf("no note about the torn line, got %q", notes.String())
+	}
+
+	// the next entry starts on a line of its own
+	l.lock()
+	if err := l.record(ledgerEntry{Analyst: "alice", Epsilon: 2.5}); err != nil {
+		t.Fatal(err)
+	}
+	l.unlock()
+	l.close()
+	l, err = openLedger(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+	if got := l.account("alice"); got.queries != 2 || got.epsilon != 7.5 {
+		t.Errorf("alice after reopening = %+v, want 2 queries and ε 7.5", got)
+	}
+}
+
+func TestLedgerRefusesDamagedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.ledger")
+	good := `{"analyst":"alice","time":"2025-01-01T00:00:00Z","epsilon":5,"delta":0,"sql":"SELECT 1"}` + "\n"
+	content := good + "not json\n" + good
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openLedger(path, io.Discard); err == nil {
+		t.Fatal("opened a ledger with a damaged entry in the middle")
+	}
+	// and left it as it was
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("damaged ledger was changed to %q", data)
+	}
+}
+
+func TestLedgerSeesOtherHandlesEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.ledger")
+	first, err := openLedger(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.close()
+	second, err := openLedger(path, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.close()
+
+	for i, epsilon := range []float64{5, 2.5, 1.25, 0.625} {
+		l := first
+		if i%2 == 1 {
+			l = second
+		}
+		if err := l.lock(); err != nil {
+			t.Fatal(err)
+		}
+		// each handle has counted the other's entries before it charges
+		if got := l.account("alice").queries; got != i {
+			t.Errorf("before entry %d the ledger counts %d queries, want %d", i+1, got, i)
+		}
+		if err := l.record(ledgerEntry{Analyst: "alice", Epsilon: epsilon}); err != nil {
+			t.Fatal(err)
+		}
+		l.unlock()
+	}
+	first.lock()
+	defer first.unlock()
+	if got := first.account("alice"); got.queries != 4 || got.epsilon != 9.375 {
+		t.Errorf("alice = %+v, want 4 queries and ε 9.375", got)
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	opts := DefaultOptions()
+	opts.K = 0
+	opts.MinEpsilon = 2
+	opts.Noise = NewSeededSource(1)
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (x INT BOUNDS(0, 10));"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := db.Query("SELECT COUNT(x) FROM t;"); err != nil {
+			t.Fatalf("SELECT %d: %v", i+1, err)
+		}
+	}
+	if _, err := db.Query("SELECT COUNT(x) FROM t;"); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("third SELECT with ε 1.25 < 2 returned %v, want ErrBudgetExhausted", err)
+	}
+}
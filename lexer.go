@@ -1,179 +1,215 @@
-package main
+package dpsql
 
 import (
 	"fmt"
+	"strings"
 )
 
-// TokenType represents the type of token.
-type TokenType int
+// tokenType represents the type of token.
+type tokenType int
 
 const (
 	// General Tokens
-	TOKEN_EOF TokenType = iota
-	TOKEN_ILLEGAL
-	TOKEN_INT
-	TOKEN_DOT
-	TOKEN_VARCHAR
-	TOKEN_FLOAT
-	TOKEN_STAR
-	TOKEN_NULL_LITERAL
-	TOKEN_COMMA
-	TOKEN_SEMICOLON
-	TOKEN_LPAREN
-	TOKEN_RPAREN
-	TOKEN_SINGLE_QUOTE
-	TOKEN_DOUBLE_QUOTE
-
-	TOKEN_INT_LITERAL
-	TOKEN_VARCHAR_LITERAL
-	TOKEN_FLOAT_LITERAL
-	TOKEN_BOOLEAN_LITERAL
-
-	TOKEN_IDENTIFIER
-	TOKEN_FUNCTION
+	tokenEOF tokenType = iota
+	tokenIllegal
+	tokenInt
+	tokenDot
+	tokenVarchar
+	tokenFloat
+	tokenStar
+	tokenNullLiteral
+	tokenComma
+	tokenSemicolon
+	tokenLParen
+	tokenRParen
+	tokenSingleQuote
+	tokenDoubleQuote
+
+	tokenIntLiteral
+	tokenVarcharLiteral
+	tokenFloatLiteral
+	tokenBooleanLiteral
+
+	tokenIdentifier
+	tokenFunction
 
 	// Data Types (additional)
-	TOKEN_BOOLEAN
-	TOKEN_DATE
-	TOKEN_TIME
-	TOKEN_TIMESTAMP
+	tokenBoolean
+	tokenDate
+	tokenTime
+	tokenTimestamp
 
 	// Operators
-	TOKEN_PLUS
-	TOKEN_MINUS
-	TOKEN_SLASH
-	TOKEN_PERCENT
-	TOKEN_EQUALS
-	TOKEN_NOT_EQUALS
-	TOKEN_LESS_THAN
-	TOKEN_GREATER_THAN
-	TOKEN_LESS_EQUAL
-	TOKEN_GREATER_EQUAL
+	tokenPlus
+	tokenMinus
+	tokenSlash
+	tokenPercent
+	tokenEquals
+	tokenNotEquals
+	tokenLessThan
+	tokenGreaterThan
+	tokenLessEqual
+	tokenGreaterEqual
 
 	// Logical Operators
-	TOKEN_AND
-	TOKEN_OR
-	TOKEN_NOT
+	tokenAnd
+	tokenOr
+	tokenNot
 
 	// Create Table & DDL
-	TOKEN_CREATE
-	TOKEN_TABLE
-	TOKEN_COLUMN
-	TOKEN_ALTER
-	TOKEN_DROP
-	TOKEN_TO
-	TOKEN_RENAME
-	TOKEN_IN
+	tokenCreate
+	tokenTable
+	tokenColumn
+	tokenAlter
+	tokenDrop
+	tokenTo
+	tokenRename
+	tokenAdd
+	tokenExists
+	tokenIn
 
 	// Constraints (additional)
-	TOKEN_PRIMARY
-	TOKEN_KEY // Alternatively, you might split into TOKEN_PRIMARY and TOKEN_KEY
-	TOKEN_UNIQUE
-	TOKEN_FOREIGN // Consider splitting as needed: TOKEN_FOREIGN, TOKEN_KEY, TOKEN_REFERENCES
-	TOKEN_REFERENCES
-	TOKEN_CHECK
-	TOKEN_DEFAULT
-	TOKEN_AUTO_INCREMENT
+	tokenPrimary
+	tokenKey // Alternatively, you might split into tokenPrimary and tokenKey
+	tokenUnique
+	tokenForeign // Consider splitting as needed: tokenForeign, tokenKey, tokenReferences
+	tokenReferences
+	tokenCascade
+	tokenRestrict
+	tokenCheck
+	tokenDefault
+	tokenAutoIncrement
+	tokenBounds
 
 	// Insert
-	TOKEN_INSERT
-	TOKEN_INTO
-	TOKEN_VALUES
+	tokenInsert
+	tokenInto
+	tokenValues
 
 	// Update
-	TOKEN_UPDATE
-	TOKEN_SET
+	tokenUpdate
+	tokenSet
 
 	// Delete
-	TOKEN_DELETE
+	tokenDelete
 
 	// Select Query
-	TOKEN_SELECT
-	TOKEN_FROM
-	TOKEN_WHERE
-	TOKEN_GROUP // For GROUP BY (use TOKEN_GROUP and TOKEN_BY together)
-	TOKEN_BY
-	TOKEN_HAVING
-	TOKEN_ORDER // For ORDER BY (use TOKEN_ORDER and TOKEN_BY)
-	TOKEN_LIMIT
-	TOKEN_OFFSET
-	TOKEN_DISTINCT
+	tokenSelect
+	tokenFrom
+	tokenWhere
+	tokenGroup // For GROUP BY (use tokenGroup and tokenBy together)
+	tokenBy
+	tokenHaving
+	tokenOrder // For ORDER BY (use tokenOrder and tokenBy)
+	tokenLimit
+	tokenOffset
+	tokenDistinct
+	tokenAsc
+	tokenDesc
+	tokenNulls
+	tokenFirst
+	tokenLast
 
 	// Expressions and Aliases
-	TOKEN_AS
-	TOKEN_CASE
-	TOKEN_WHEN
-	TOKEN_THEN
-	TOKEN_ELSE
-	TOKEN_END
+	tokenAs
+	tokenCase
+	tokenWhen
+	tokenThen
+	tokenElse
+	tokenEnd
 
 	// Joins
-	TOKEN_JOIN
-	TOKEN_INNER
-	TOKEN_LEFT
-	TOKEN_RIGHT
-	TOKEN_FULL
-	TOKEN_ON
+	tokenJoin
+	tokenInner
+	tokenLeft
+	tokenRight
+	tokenFull
+	tokenOuter
+	tokenOn
 
 	// Additional Conditional Tokens
-	TOKEN_IF
-	TOKEN_NOT_EXISTS
+	tokenIf
+	tokenNotExists
 
-	// Transaction Control (if needed)
-	TOKEN_COMMIT
-	TOKEN_ROLLBACK
-	TOKEN_SAVEPOINT
+	// Transaction Control
+	tokenBegin
+	tokenTransaction
+	tokenCommit
+	tokenRollback
+	tokenSavepoint
+	tokenRelease
 
 	// Data Control (if needed)
-	TOKEN_GRANT
-	TOKEN_REVOKE
+	tokenGrant
+	tokenRevoke
 
-	// The following tokens are referenced in LookupKeyword. They are not defined above.
+	// The following tokens are referenced in lookupKeyword. They are not defined above.
 	// You can define them if you plan to support these functions.
-	// TOKEN_TRUNCATE, TOKEN_COUNT, TOKEN_SUM, TOKEN_MAX, TOKEN_MIN, TOKEN_AVG,
-	// TOKEN_UNION, TOKEN_EXCEPT, TOKEN_INTERSECT, TOKEN_IS, TOKEN_NULL, TOKEN_TRUE, TOKEN_FALSE
-	TOKEN_TRUNCATE
-	TOKEN_COUNT
-	TOKEN_SUM
-	TOKEN_MAX
-	TOKEN_MIN
-	TOKEN_AVG
-	TOKEN_UNION
-	TOKEN_EXCEPT
-	TOKEN_INTERSECT
-	TOKEN_IS
-	TOKEN_NULL
-	TOKEN_TRUE
-	TOKEN_FALSE
+	// tokenTruncate, tokenCount, tokenSum, tokenMax, tokenMin, tokenAvg,
+	// tokenUnion, tokenExcept, tokenIntersect, tokenIs, tokenNull, tokenTrue, tokenFalse
+	tokenTruncate
+	tokenShow
+	tokenCount
+	tokenSum
+	tokenMax
+	tokenMin
+	tokenAvg
+	tokenUnion
+	tokenExcept
+	tokenIntersect
+	tokenIs
+	tokenNull
+	tokenTrue
+	tokenFalse
 )
 
-// Token represents a lexical token.
-type Token struct {
-	_type TokenType
+// lexToken represents a lexical token.
+type lexToken struct {
+	_type tokenType
 	value string
+	// byte offsets of the lexeme in the input, so a statement's text can be
+	// recovered from its first and last token
+	start int
+	end   int
+	// where the lexeme starts, counting from 1, for error messages
+	line int
+	col  int
 }
 
-// Lexer holds information about the lexing process.
-type Lexer struct {
+// lexState holds information about the lexing process.
+type lexState struct {
 	start   int
 	current int
 	line    int
 	input   string
+	// offset of the first character of the current line
+	lineStart int
+	// position of the token being scanned
+	startLine int
+	startCol  int
+	// type of the last token returned, to tell a negative number from a
+	// subtraction
+	prev tokenType
 }
 
 // atEnd checks if we have reached the end of the input.
-func atEnd(lexer *Lexer) bool {
+func atEnd(lexer *lexState) bool {
 	return lexer.current >= len(lexer.input)
 }
 
 // advance consumes the current character and returns it.
-func advance(lexer *Lexer) byte {
+func advance(lexer *lexState) byte {
 	lexer.current++
-	return lexer.input[lexer.current-1]
+	c := lexer.input[lexer.current-1]
+	if c == '\n' {
+		lexer.line++
+		lexer.lineStart = lexer.current
+	}
+	return c
 }
 
 // peek returns the current character without consuming it.
-func peek(lexer *Lexer) byte {
+func peek(lexer *lexState) byte {
 	if lexer.current < len(lexer.input) {
 		return lexer.input[lexer.current]
 	} else {
@@ -183,20 +219,34 @@ func peek(lexer *Lexer) byte {
 }
 
 // peekNext returns the character after the current one.
-func peekNext(lexer *Lexer) byte {
+func peekNext(lexer *lexState) byte {
 	if lexer.current+1 >= len(lexer.input) {
 		return 0
 	}
 	return lexer.input[lexer.current+1]
 }
 
-// skipWhitespace advances the lexer past any whitespace characters.
-func skipWhitespace(lexer *Lexer) {
+// skipWhitespace advances the lexer past any whitespace characters and
+// comments: -- to the end of the line, and /* ... */. A /* without its */ is
+// left for getNextToken to report.
+func skipWhitespace(lexer *lexState) {
 	for {
 		c := peek(lexer)
-		switch c {
-		case ' ', '\t', '\n', '\r':
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
 			advance(lexer)
+		case c == '-' && peekNext(lexer) == '-':
+			for !atEnd(lexer) && peek(lexer) != '\n' {
+				advance(lexer)
+			}
+		case c == '/' && peekNext(lexer) == '*':
+			end := strings.Index(lexer.input[lexer.current+2:], "*/")
+			if end < 0 {
+				return
+			}
+			for stop := lexer.current + 2 + end + 2; lexer.current < stop; {
+				advance(lexer)
+			}
 		default:
 			return
 		}
@@ -206,11 +256,36 @@ func skipWhitespace(lexer *Lexer) {
 	}
 }
 
+// scanQuoted scans the rest of a string or identifier whose opening quote
+// has been consumed, where two quotes in a row stand for one. It returns the
+// unescaped text, or false if the closing quote is missing.
+func scanQuoted(lexer *lexState, quote byte) (string, bool) {
+	for {
+		for !atEnd(lexer) && peek(lexer) != quote {
+			advance(lexer)
+		}
+		if atEnd(lexer) {
+			return "", false
+		}
+		advance(lexer) // Consume closing quote
+		if peek(lexer) != quote {
+			break
+		}
+		advance(lexer) // Consume the second of two quotes
+	}
+	value := lexer.input[lexer.start+1 : lexer.current-1] // Exclude quotes
+	return strings.ReplaceAll(value, string([]byte{quote, quote}), string(quote)), true
+}
+
 // makeToken creates a new token from the current lexeme.
-func makeToken(lexer *Lexer, _type TokenType, val string) Token {
-	return Token{
+func makeToken(lexer *lexState, _type tokenType, val string) lexToken {
+	return lexToken{
 		_type: _type,
 		value: val,
+		start: lexer.start,
+		end:   lexer.current,
+		line:  lexer.startLine,
+		col:   lexer.startCol,
 	}
 }
 
@@ -224,293 +299,611 @@ func isDigit(c byte) bool {
 	return c >= '0' && c <= '9'
 }
 
-// scanNumber scans a numeric literal (integer or float).
-func scanNumber(lexer *Lexer) Token {
-	for !atEnd(lexer) && (isDigit(peek(lexer)) || peek(lexer) == '.') {
+// scanNumber scans a numeric literal: digits, then optionally a fraction and
+// an exponent, as in 42, 37.1 or -3.5e2. Anything with a fraction or an
+// exponent is a float. The first character, a digit or '-', has already been
+// consumed.
+func scanNumber(lexer *lexState) lexToken {
+	_type := tokenIntLiteral
+	for !atEnd(lexer) && isDigit(peek(lexer)) {
 		advance(lexer)
 	}
-	return makeToken(lexer, TOKEN_INT_LITERAL, lexer.input[lexer.start:lexer.current])
+	if peek(lexer) == '.' && isDigit(peekNext(lexer)) {
+		_type = tokenFloatLiteral
+		advance(lexer) // Consume '.'
+		for !atEnd(lexer) && isDigit(peek(lexer)) {
+			advance(lexer)
+		}
+	}
+	if peek(lexer) == 'e' || peek(lexer) == 'E' {
+		// only an exponent if digits follow, so 2e is 2 then e
+		exponent := lexer.current + 1
+		if exponent < len(lexer.input) && (lexer.input[exponent] == '+' || lexer.input[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(lexer.input) && isDigit(lexer.input[exponent]) {
+			_type = tokenFloatLiteral
+			for lexer.current < exponent {
+				advance(lexer)
+			}
+			for !atEnd(lexer) && isDigit(peek(lexer)) {
+				advance(lexer)
+			}
+		}
+	}
+	return makeToken(lexer, _type, lexer.input[lexer.start:lexer.current])
 }
 
-// LookupKeyword returns the token type corresponding to a keyword.
-func LookupKeyword(value string) TokenType {
-	switch value {
+// endsOperand reports whether a token of type t can end an operand, in which
+// case a '-' after it is a subtraction rather than the sign of a number.
+func endsOperand(t tokenType) bool {
+	switch t {
+	case tokenIdentifier, tokenIntLiteral, tokenFloatLiteral, tokenVarcharLiteral,
+		tokenBooleanLiteral, tokenTrue, tokenFalse, tokenNull, tokenRParen:
+		return true
+	}
+	return false
+}
+
+// lookupKeyword returns the token type corresponding to a keyword, in any
+// case.
+func lookupKeyword(value string) tokenType {
+	switch strings.ToUpper(value) {
 	case "SELECT":
-		return TOKEN_SELECT
+		return tokenSelect
 	case "INSERT":
-		return TOKEN_INSERT
+		return tokenInsert
 	case "UPDATE":
-		return TOKEN_UPDATE
+		return tokenUpdate
 	case "DELETE":
-		return TOKEN_DELETE
+		return tokenDelete
 	case "CREATE":
-		return TOKEN_CREATE
+		return tokenCreate
 	case "ALTER":
-		return TOKEN_ALTER
+		return tokenAlter
 	case "DROP":
-		return TOKEN_DROP
+		return tokenDrop
 	case "TRUNCATE":
-		return TOKEN_TRUNCATE
+		return tokenTruncate
+	case "SHOW":
+		return tokenShow
+	case "RENAME":
+		return tokenRename
+	case "TO":
+		return tokenTo
+	case "ADD":
+		return tokenAdd
+	case "COLUMN":
+		return tokenColumn
+	case "IF":
+		return tokenIf
+	case "EXISTS":
+		return tokenExists
+	case "DEFAULT":
+		return tokenDefault
+	case "UNIQUE":
+		return tokenUnique
+	case "CHECK":
+		return tokenCheck
+	case "AUTO_INCREMENT":
+		return tokenAutoIncrement
+	case "BOUNDS":
+		return tokenBounds
+	case "FOREIGN":
+		return tokenForeign
+	case "REFERENCES":
+		return tokenReferences
+	case "CASCADE":
+		return tokenCascade
+	case "RESTRICT":
+		return tokenRestrict
+
+	case "BEGIN":
+		return tokenBegin
+	case "TRANSACTION":
+		return tokenTransaction
+	case "COMMIT":
+		return tokenCommit
+	case "ROLLBACK":
+		return tokenRollback
+	case "SAVEPOINT":
+		return tokenSavepoint
+	case "RELEASE":
+		return tokenRelease
 
 	case "FROM":
-		return TOKEN_FROM
+		return tokenFrom
 	case "WHERE":
-		return TOKEN_WHERE
+		return tokenWhere
 
 	case "COUNT":
-		return TOKEN_COUNT
+		return tokenCount
 	case "SUM":
-		return TOKEN_SUM
+		return tokenSum
 	case "MAX":
-		return TOKEN_MAX
+		return tokenMax
 	case "MIN":
-		return TOKEN_MIN
+		return tokenMin
 	case "AVG":
-		return TOKEN_AVG
+		return tokenAvg
 
 	case "GROUP":
-		return TOKEN_GROUP
+		return tokenGroup
 	case "BY":
-		return TOKEN_BY
+		return tokenBy
 	case "HAVING":
-		return TOKEN_HAVING
+		return tokenHaving
 
 	case "ORDER":
-		return TOKEN_ORDER
+		return tokenOrder
 	case "LIMIT":
-		return TOKEN_LIMIT
+		return tokenLimit
 	case "OFFSET":
-		return TOKEN_OFFSET
+		return tokenOffset
+	case "ASC":
+		return tokenAsc
+	case "DESC":
+		return tokenDesc
+	case "NULLS":
+		return tokenNulls
+	case "FIRST":
+		return tokenFirst
+	case "LAST":
+		return tokenLast
 
 	case "JOIN":
-		return TOKEN_JOIN
+		return tokenJoin
 	case "INNER":
-		return TOKEN_INNER
+		return tokenInner
 	case "LEFT":
-		return TOKEN_LEFT
+		return tokenLeft
 	case "RIGHT":
-		return TOKEN_RIGHT
+		return tokenRight
 	case "FULL":
-		return TOKEN_FULL
+		return tokenFull
+	case "OUTER":
+		return tokenOuter
 	case "ON":
-		return TOKEN_ON
+		return tokenOn
 
 	case "DISTINCT":
-		return TOKEN_DISTINCT
+		return tokenDistinct
 	case "AS":
-		return TOKEN_AS
+		return tokenAs
 
 	case "VALUES":
-		return TOKEN_VALUES
+		return tokenValues
 	case "SET":
-		return TOKEN_SET
+		return tokenSet
 
 	case "CASE":
-		return TOKEN_CASE
+		return tokenCase
 	case "WHEN":
-		return TOKEN_WHEN
+		return tokenWhen
 	case "THEN":
-		return TOKEN_THEN
+		return tokenThen
 	case "ELSE":
-		return TOKEN_ELSE
+		return tokenElse
 	case "END":
-		return TOKEN_END
+		return tokenEnd
 
 	case "UNION":
-		return TOKEN_UNION
+		return tokenUnion
 	case "EXCEPT":
-		return TOKEN_EXCEPT
+		return tokenExcept
 	case "INTERSECT":
-		return TOKEN_INTERSECT
+		return tokenIntersect
 
 	case "AND":
-		return TOKEN_AND
+		return tokenAnd
 	case "OR":
-		return TOKEN_OR
+		return tokenOr
 	case "NOT":
-		return TOKEN_NOT
+		return tokenNot
 
 	case "IN":
-		return TOKEN_IN
+		return tokenIn
 	case "IS":
-		return TOKEN_IS
+		return tokenIs
 	case "NULL":
-		return TOKEN_NULL
+		return tokenNull
 
 	case "TRUE":
-		return TOKEN_TRUE
+		return tokenTrue
 	case "FALSE":
-		return TOKEN_FALSE
+		return tokenFalse
 	case "PRIMARY":
-		return TOKEN_PRIMARY
+		return tokenPrimary
 	case "KEY":
-		return TOKEN_KEY
+		return tokenKey
 	case "VARCHAR":
-		return TOKEN_VARCHAR
+		return tokenVarchar
 	case "INT":
-		return TOKEN_INT
+		return tokenInt
 	case "FLOAT":
-		return TOKEN_FLOAT
+		return tokenFloat
 
 	case "TABLE":
-		return TOKEN_TABLE
+		return tokenTable
 	case "INTO":
-		return TOKEN_INTO
+		return tokenInto
 
 	default:
-		return TOKEN_IDENTIFIER
+		return tokenIdentifier
 	}
 }
 
 // scanIdentifier scans an identifier or keyword.
-func scanIdentifier(lexer *Lexer) Token {
+func scanIdentifier(lexer *lexState) lexToken {
 	for !atEnd(lexer) && (isAlphabetic(peek(lexer)) || isDigit(peek(lexer))) {
 		advance(lexer)
 	}
 	lexeme := lexer.input[lexer.start:lexer.current]
-	return makeToken(lexer, LookupKeyword(lexeme), lexeme)
+	return makeToken(lexer, lookupKeyword(lexeme), lexeme)
+}
+
+// quoteIdentifier writes a name so that it lexes back as the same
+// identifier: as is when it's a plain name, in double quotes when it has
+// other characters or is a keyword.
+func quoteIdentifier(name string) string {
+	plain := name != ""
+	for i := 0; i < len(name); i++ {
+		if !isAlphabetic(name[i]) && !(i > 0 && isDigit(name[i])) {
+			plain = false
+		}
+	}
+	if plain && lookupKeyword(name) == tokenIdentifier {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// tokenize runs the lexer over a whole input and returns its tokens, ending
+// with tokenEOF.
+func tokenize(input string) []*lexToken {
+	lexer := &lexState{
+		input:   input,
+		start:   0,
+		current: 0,
+		line:    1,
+	}
+
+	var tokens []*lexToken
+	for {
+		token := getNextToken(lexer)
+		lexer.prev = token._type
+		tokens = append(tokens, &token)
+		if token._type == tokenEOF {
+			return tokens
+		}
+	}
 }
 
 // getNextToken returns the next token from the input.
-func getNextToken(lexer *Lexer) Token {
+func getNextToken(lexer *lexState) lexToken {
 	skipWhitespace(lexer)
+	lexer.start = lexer.current
+	lexer.startLine = lexer.line
+	lexer.startCol = lexer.current - lexer.lineStart + 1
 	if atEnd(lexer) {
-		return makeToken(lexer, TOKEN_EOF, "")
+		return makeToken(lexer, tokenEOF, "")
+	}
+	if peek(lexer) == '/' && peekNext(lexer) == '*' {
+		// skipWhitespace leaves a comment that never ends
+		for !atEnd(lexer) {
+			advance(lexer)
+		}
+		return makeToken(lexer, tokenIllegal, "Unterminated comment")
 	}
 
-	lexer.start = lexer.current
 	c := advance(lexer)
 
 	// Check for identifiers
 	if isAlphabetic(c) || c == '_' {
 		return scanIdentifier(lexer)
 	}
-	// Check for numbers
-	if isDigit(c) {
+	// Check for numbers, including negative ones
+	if isDigit(c) || (c == '-' && isDigit(peek(lexer)) && !endsOperand(lexer.prev)) {
 		return scanNumber(lexer)
 	}
 
 	switch c {
 	case ';':
-		return makeToken(lexer, TOKEN_SEMICOLON, ";")
+		return makeToken(lexer, tokenSemicolon, ";")
 	case '(':
-		return makeToken(lexer, TOKEN_LPAREN, "(")
+		return makeToken(lexer, tokenLParen, "(")
 	case ')':
-		return makeToken(lexer, TOKEN_RPAREN, ")")
+		return makeToken(lexer, tokenRParen, ")")
 	case ',':
-		return makeToken(lexer, TOKEN_COMMA, ",")
+		return makeToken(lexer, tokenComma, ",")
 	case '+':
-		return makeToken(lexer, TOKEN_PLUS, "+")
+		return makeToken(lexer, tokenPlus, "+")
 	case '-':
-		return makeToken(lexer, TOKEN_MINUS, "-")
+		return makeToken(lexer, tokenMinus, "-")
 	case '*':
-		return makeToken(lexer, TOKEN_STAR, "*")
+		return makeToken(lexer, tokenStar, "*")
 	case '/':
-		return makeToken(lexer, TOKEN_SLASH, "/")
+		return makeToken(lexer, tokenSlash, "/")
 	case '%':
-		return makeToken(lexer, TOKEN_PERCENT, "%")
+		return makeToken(lexer, tokenPercent, "%")
 	case '=':
-		return makeToken(lexer, TOKEN_EQUALS, "=")
+		return makeToken(lexer, tokenEquals, "=")
 	case '<':
 		if peek(lexer) == '=' {
 			advance(lexer)
-			return makeToken(lexer, TOKEN_LESS_EQUAL, "<=")
+			return makeToken(lexer, tokenLessEqual, "<=")
 		} else if peek(lexer) == '>' {
 			advance(lexer)
-			return makeToken(lexer, TOKEN_NOT_EQUALS, "<>")
+			return makeToken(lexer, tokenNotEquals, "<>")
 		}
-		return makeToken(lexer, TOKEN_LESS_THAN, "<")
+		return makeToken(lexer, tokenLessThan, "<")
 	case '>':
 		if peek(lexer) == '=' {
 			advance(lexer)
-			return makeToken(lexer, TOKEN_GREATER_EQUAL, ">=")
+			return makeToken(lexer, tokenGreaterEqual, ">=")
 		}
-		return makeToken(lexer, TOKEN_GREATER_THAN, ">")
+		return makeToken(lexer, tokenGreaterThan, ">")
 	case '.':
-		return makeToken(lexer, TOKEN_DOT, ".")
+		return makeToken(lexer, tokenDot, ".")
 
 	case '\'':
 		// Start scanning a VARCHAR literal
-		for !atEnd(lexer) && peek(lexer) != '\'' {
-			advance(lexer)
-		}
-		if atEnd(lexer) {
-			return makeToken(lexer, TOKEN_ILLEGAL, "Unterminated string")
+		value, ok := scanQuoted(lexer, '\'')
+		if !ok {
+			return makeToken(lexer, tokenIllegal, "Unterminated string")
 		}
-		advance(lexer)                                        // Consume closing quote
-		value := lexer.input[lexer.start+1 : lexer.current-1] // Exclude quotes
-		return makeToken(lexer, TOKEN_VARCHAR_LITERAL, value)
+		return makeToken(lexer, tokenVarcharLiteral, value)
 
 	case '"':
-		return makeToken(lexer, TOKEN_DOUBLE_QUOTE, "\"")
+		// A quoted identifier keeps its case and may be a keyword
+		value, ok := scanQuoted(lexer, '"')
+		if !ok {
+			return makeToken(lexer, tokenIllegal, "Unterminated quoted identifier")
+		}
+		if value == "" {
+			return makeToken(lexer, tokenIllegal, "Empty quoted identifier")
+		}
+		return makeToken(lexer, tokenIdentifier, value)
 	default:
-		return makeToken(lexer, TOKEN_ILLEGAL, string(c))
+		return makeToken(lexer, tokenIllegal, string(c))
 	}
 }
 
-func tokenTypeToString(t TokenType) string {
+func tokenTypeToString(t tokenType) string {
 	switch t {
-	case TOKEN_EOF:
+	case tokenEOF:
 		return "EOF"
-	case TOKEN_ILLEGAL:
+	case tokenIllegal:
 		return "ILLEGAL"
-	case TOKEN_INT_LITERAL:
+	case tokenIntLiteral:
 		return "INT_LITERAL"
-	case TOKEN_VARCHAR_LITERAL:
+	case tokenVarcharLiteral:
 		return "VARCHAR_LITERAL"
-	case TOKEN_FLOAT_LITERAL:
+	case tokenFloatLiteral:
 		return "FLOAT_LITERAL"
-	case TOKEN_BOOLEAN_LITERAL:
+	case tokenBooleanLiteral:
 		return "BOOLEAN_LITERAL"
-	case TOKEN_NULL_LITERAL:
+	case tokenNullLiteral:
 		return "NULL_LITERAL"
-	case TOKEN_IDENTIFIER:
+	case tokenIdentifier:
 		return "IDENTIFIER"
-	case TOKEN_SELECT:
+	case tokenSelect:
 		return "SELECT"
-	case TOKEN_CREATE:
+	case tokenCreate:
 		return "CREATE"
-	case TOKEN_TABLE:
+	case tokenTable:
 		return "TABLE"
-	case TOKEN_VARCHAR:
+	case tokenVarchar:
 		return "VARCHAR"
-	case TOKEN_INT:
+	case tokenInt:
 		return "INT"
-	case TOKEN_INSERT:
+	case tokenInsert:
 		return "INSERT"
-	case TOKEN_INTO:
+	case tokenInto:
 		return "INTO"
-	case TOKEN_VALUES:
+	case tokenValues:
 		return "VALUES"
-	case TOKEN_FROM:
+	case tokenFrom:
 		return "FROM"
-	case TOKEN_AS:
+	case tokenAs:
 		return "AS"
-	case TOKEN_PRIMARY:
+	case tokenPrimary:
 		return "PRIMARY"
-	case TOKEN_KEY:
+	case tokenKey:
 		return "KEY"
-	case TOKEN_LPAREN:
+	case tokenLParen:
 		return "LPAREN"
-	case TOKEN_RPAREN:
+	case tokenRParen:
 		return "RPAREN"
-	case TOKEN_COMMA:
+	case tokenComma:
 		return "COMMA"
-	case TOKEN_SEMICOLON:
+	case tokenSemicolon:
 		return "SEMICOLON"
-	case TOKEN_SINGLE_QUOTE:
+	case tokenSingleQuote:
 		return "SINGLE_QUOTE"
-	case TOKEN_COUNT:
+	case tokenCount:
 		return "COUNT"
-	case TOKEN_SUM:
+	case tokenSum:
 		return "SUM"
-	case TOKEN_AVG:
+	case tokenAvg:
 		return "AVG"
-	case TOKEN_MIN:
+	case tokenMin:
 		return "MIN"
-	case TOKEN_MAX:
+	case tokenMax:
 		return "MAX"
+	case tokenDot:
+		return "DOT"
+	case tokenFloat:
+		return "FLOAT"
+	case tokenStar:
+		return "STAR"
+	case tokenDoubleQuote:
+		return "DOUBLE_QUOTE"
+	case tokenFunction:
+		return "FUNCTION"
+	case tokenBoolean:
+		return "BOOLEAN"
+	case tokenDate:
+		return "DATE"
+	case tokenTime:
+		return "TIME"
+	case tokenTimestamp:
+		return "TIMESTAMP"
+	case tokenPlus:
+		return "PLUS"
+	case tokenMinus:
+		return "MINUS"
+	case tokenSlash:
+		return "SLASH"
+	case tokenPercent:
+		return "PERCENT"
+	case tokenEquals:
+		return "EQUALS"
+	case tokenNotEquals:
+		return "NOT_EQUALS"
+	case tokenLessThan:
+		return "LESS_THAN"
+	case tokenGreaterThan:
+		return "GREATER_THAN"
+	case tokenLessEqual:
+		return "LESS_EQUAL"
+	case tokenGreaterEqual:
+		return "GREATER_EQUAL"
+	case tokenAnd:
+		return "AND"
+	case tokenOr:
+		return "OR"
+	case tokenNot:
+		return "NOT"
+	case tokenColumn:
+		return "COLUMN"
+	case tokenAlter:
+		return "ALTER"
+	case tokenDrop:
+		return "DROP"
+	case tokenTo:
+		return "TO"
+	case tokenRename:
+		return "RENAME"
+	case tokenAdd:
+		return "ADD"
+	case tokenExists:
+		return "EXISTS"
+	case tokenIn:
+		return "IN"
+	case tokenUnique:
+		return "UNIQUE"
+	case tokenForeign:
+		return "FOREIGN"
+	case tokenReferences:
+		return "REFERENCES"
+	case tokenCascade:
+		return "CASCADE"
+	case tokenRestrict:
+		return "RESTRICT"
+	case tokenCheck:
+		return "CHECK"
+	case tokenDefault:
+		return "DEFAULT"
+	case tokenAutoIncrement:
+		return "AUTO_INCREMENT"
+	case tokenBounds:
+		return "BOUNDS"
+	case tokenUpdate:
+		return "UPDATE"
+	case tokenSet:
+		return "SET"
+	case tokenDelete:
+		return "DELETE"
+	case tokenWhere:
+		return "WHERE"
+	case tokenGroup:
+		return "GROUP"
+	case tokenBy:
+		return "BY"
+	case tokenHaving:
+		return "HAVING"
+	case tokenOrder:
+		return "ORDER"
+	case tokenLimit:
+		return "LIMIT"
+	case tokenOffset:
+		return "OFFSET"
+	case tokenDistinct:
+		return "DISTINCT"
+	case tokenAsc:
+		return "ASC"
+	case tokenDesc:
+		return "DESC"
+	case tokenNulls:
+		return "NULLS"
+	case tokenFirst:
+		return "FIRST"
+	case tokenLast:
+		return "LAST"
+	case tokenCase:
+		return "CASE"
+	case tokenWhen:
+		return "WHEN"
+	case tokenThen:
+		return "THEN"
+	case tokenElse:
+		return "ELSE"
+	case tokenEnd:
+		return "END"
+	case tokenJoin:
+		return "JOIN"
+	case tokenInner:
+		return "INNER"
+	case tokenLeft:
+		return "LEFT"
+	case tokenRight:
+		return "RIGHT"
+	case tokenFull:
+		return "FULL"
+	case tokenOuter:
+		return "OUTER"
+	case tokenOn:
+		return "ON"
+	case tokenIf:
+		return "IF"
+	case tokenNotExists:
+		return "NOT_EXISTS"
+	case tokenBegin:
+		return "BEGIN"
+	case tokenTransaction:
+		return "TRANSACTION"
+	case tokenCommit:
+		return "COMMIT"
+	case tokenRollback:
+		return "ROLLBACK"
+	case tokenSavepoint:
+		return "SAVEPOINT"
+	case tokenRelease:
+		return "RELEASE"
+	case tokenGrant:
+		return "GRANT"
+	case tokenRevoke:
+		return "REVOKE"
+	case tokenTruncate:
+		return "TRUNCATE"
+	case tokenShow:
+		return "SHOW"
+	case tokenUnion:
+		return "UNION"
+	case tokenExcept:
+		return "EXCEPT"
+	case tokenIntersect:
+		return "INTERSECT"
+	case tokenIs:
+		return "IS"
+	case tokenNull:
+		return "NULL"
+	case tokenTrue:
+		return "TRUE"
+	case tokenFalse:
+		return "FALSE"
 	default:
-		return fmt.Sprintf("TokenType(%d)", t)
+		return fmt.Sprintf("tokenType(%d)", t)
 	}
 }
@@ -0,0 +1,90 @@
+package dpsql
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"-3.5e2", []string{"FLOAT_LITERAL -3.5e2"}},
+		{"42 37.1 1e3 2E-2 4e+1", []string{"INT_LITERAL 42", "FLOAT_LITERAL 37.1", "FLOAT_LITERAL 1e3", "FLOAT_LITERAL 2E-2", "FLOAT_LITERAL 4e+1"}},
+		// no digits after the e: 2 and then the identifier e
+		{"2e", []string{"INT_LITERAL 2", "IDENTIFIER e"}},
+		{"3.", []string{"INT_LITERAL 3", "DOT ."}},
+		{"'O''Brien'", []string{"VARCHAR_LITERAL O'Brien"}},
+		{"''''", []string{"VARCHAR_LITERAL '"}},
+		{"'a -- b'", []string{"VARCHAR_LITERAL a -- b"}},
+		// after an operand, - is a subtraction
+		{"x -1", []string{"IDENTIFIER x", "MINUS -", "INT_LITERAL 1"}},
+		{"2-1", []string{"INT_LITERAL 2", "MINUS -", "INT_LITERAL 1"}},
+		{"(a) -2", []string{"LPAREN (", "IDENTIFIER a", "RPAREN )", "MINUS -", "INT_LITERAL 2"}},
+		// anywhere else it is the sign of a number
+		{"x - -1", []string{"IDENTIFIER x", "MINUS -", "INT_LITERAL -1"}},
+		{"= -1.5", []string{"EQUALS =", "FLOAT_LITERAL -1.5"}},
+		{"(-1, -2)", []string{"LPAREN (", "INT_LITERAL -1", "COMMA ,", "INT_LITERAL -2", "RPAREN )"}},
+		{"- x", []string{"MINUS -", "IDENTIFIER x"}},
+		{`"select" sElEcT "a""b"`, []string{"IDENTIFIER select", "SELECT sElEcT", `IDENTIFIER a"b`}},
+		{"1 -- to the end of the line\n2 /* in\nbetween */ 3", []string{"INT_LITERAL 1", "INT_LITERAL 2", "INT_LITERAL 3"}},
+		{"<> <= >= < >", []string{"NOT_EQUALS <>", "LESS_EQUAL <=", "GREATER_EQUAL >=", "LESS_THAN <", "GREATER_THAN >"}},
+		{"'abc", []string{"ILLEGAL Unterminated string"}},
+		{`"abc`, []string{"ILLEGAL Unterminated quoted identifier"}},
+		{`""`, []string{"ILLEGAL Empty quoted identifier"}},
+		{"1 /* abc", []string{"INT_LITERAL 1", "ILLEGAL Unterminated comment"}},
+		{"#", []string{"ILLEGAL #"}},
+	}
+	for _, test := range tests {
+		tokens := tokenize(test.input)
+		var got []string
+		for _, token := range tokens[:len(tokens)-1] {
+			got = append(got, tokenTypeToString(token._type)+" "+token.value)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("tokenize(%q) = %q, want %q", test.input, got, test.want)
+		}
+		if last := tokens[len(tokens)-1]; last._type != tokenEOF {
+			t.Errorf("tokenize(%q) ends with %s, want EOF", test.input, tokenTypeToString(last._type))
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	tokens := tokenize("SELECT a\n  FROM 'it''s' -- x\n/* y */ 1;")
+	var got []string
+	for _, token := range tokens {
+		got = append(got, fmt.Sprintf("%s@%d:%d[%d:%d]", token.value, token.line, token.col, token.start, token.end))
+	}
+	want := []string{"SELECT@1:1[0:6]", "a@1:8[7:8]", "FROM@2:3[11:15]", "it's@2:8[16:23]", "1@3:9[37:38]", ";@3:10[38:39]", "@3:11[39:39]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("positions = %q, want %q", got, want)
+	}
+}
+
+func TestLiteralsInStatements(t *testing.T) {
+	db, err := Open(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE t (name VARCHAR(20), x INT, f FLOAT, y INT);
+INSERT INTO t (name, x, f) VALUES ('O''Brien', -3, -3.5e2);
+INSERT INTO t (name, x, f) VALUES ('-- no comment', 5, 1E1);
+UPDATE t SET y = x -1;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, check := range []struct{ column, want string }{
+		{"name", "[O'Brien -- no comment]"},
+		{"x", "[-3 5]"},
+		{"f", "[-350 10]"},
+		{"y", "[-4 4]"},
+	} {
+		if got := columnValues(db, "t", check.column); got != check.want {
+			t.Errorf("%s = %s, want %s", check.column, got, check.want)
+		}
+	}
+}
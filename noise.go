@@ -0,0 +1,157 @@
+package dpsql
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math"
+	"math/rand"
+)
+
+// NoiseSource supplies the random bits that noise is drawn from. A DB calls
+// it while holding its lock, so it needn't be safe for concurrent use.
+type NoiseSource interface {
+	Uint64() uint64
+}
+
+// NewCryptoSource returns a NoiseSource that reads from crypto/rand. It is
+// what a DB uses when Options.Noise is nil.
+func NewCryptoSource() NoiseSource {
+	return cryptoSource{}
+}
+
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic("dpsql: reading crypto/rand: " + err.Error())
+	}
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// NewSeededSource returns a predictable NoiseSource, so that tests and
+// demonstrations can repeat a run exactly. It must not protect real data.
+func NewSeededSource(seed int64) NoiseSource {
+	return rand.New(rand.NewSource(seed))
+}
+
+// noiseGrid is how many grid steps fit in the noise scale. Noise lands on
+// a grid of powers of two, about a thousandth of the scale apart.
+const noiseGrid = 10
+
+// sampleLaplace draws Laplace noise with scale b around trueValue for an
+// aggregate with the given sensitivity. Textbook inverse-CDF sampling on
+// floats leaks the true value through which doubles can come out
+// (Mironov, 2012), so this rounds the value to a grid of step γ, a power of
+// two, and adds discrete Laplace noise, a whole number of steps. Every
+// result is a multiple of γ whatever the input, and rounding can move a
+// neighbouring result by γ more, which the scale allows for.
+func sampleLaplace(trueValue float64, b float64, sensitivity float64, source NoiseSource) float64 {
+	if b <= 0 || math.IsInf(b, 0) || math.IsNaN(b) {
+		return trueValue
+	}
+	gamma := math.Ldexp(1, math.Ilogb(b)-noiseGrid)
+	// scale in grid steps, widened for the rounding
+	t := b * (sensitivity + gamma) / sensitivity / gamma
+	steps := sampleGeometric(t, source) - sampleGeometric(t, source)
+	return (math.Round(trueValue/gamma) + steps) * gamma
+}
+
+// sampleGeometric draws the number of failures before a success with
+// success probability 1 - exp(-1/t). The difference of two such draws is
+// discrete Laplace: P(k) ∝ exp(-|k|/t).
+func sampleGeometric(t float64, source NoiseSource) float64 {
+	return math.Floor(-t * math.Log(uniformOpenClosed(source)))
+}
+
+// uniformOpenClosed returns a uniform float in (0, 1], using 53 random bits
+// so that the log above is always finite.
+func uniformOpenClosed(source NoiseSource) float64 {
+	return float64(source.Uint64()>>11+1) / (1 << 53)
+}
+
+// gaussianScale works out the σ of the Gaussian noise for each aggregate of
+// a row, and the grid step γ that noise lands on. Each aggregate is divided
+// by its own sensitivity first, so one row can move each by at most 1 and
+// the whole row by √d in L2, and its noise is scaled back up: a COUNT next
+// to a SUM of large values keeps noise to its own size. Rounding to the
+// grid can move an aggregate of a neighbouring row by γ more, so the L2
+// sensitivity is taken over the widened values.
+func gaussianScale(epsilon float64, delta float64, sensitivities map[string]float64) (sigmas map[string]float64, gammas map[string]float64) {
+	sigmas, gammas = make(map[string]float64), make(map[string]float64)
+	d := 0
+	for _, s := range sensitivities {
+		if s > 0 {
+			d++
+		}
+	}
+	if d == 0 {
+		return sigmas, gammas
+	}
+	unit := gaussianSigma(epsilon, delta, math.Sqrt(float64(d)))
+	widened := 0.0
+	for col, s := range sensitivities {
+		if s > 0 {
+			gammas[col] = math.Ldexp(1, math.Ilogb(s*unit)-noiseGrid)
+			widened += (1 + gammas[col]/s) * (1 + gammas[col]/s)
+		}
+	}
+	unit = gaussianSigma(epsilon, delta, math.Sqrt(widened))
+	for col, s := range sensitivities {
+		if s > 0 {
+			sigmas[col] = s * unit
+		}
+	}
+	return sigmas, gammas
+}
+
+// gaussianSigma is the smallest σ for which Gaussian noise on a result with
+// L2 sensitivity l2 is (ε, δ)-differentially private. It uses the exact
+// condition of the analytic Gaussian mechanism (Balle and Wang, 2018),
+//
+//	Φ(Δ/2σ − εσ/Δ) − e^ε·Φ(−Δ/2σ − εσ/Δ) ≤ δ,
+//
+// rather than the textbook σ = Δ·√(2·ln(1.25/δ))/ε, which only holds for
+// ε < 1 and budget shares here are often larger.
+func gaussianSigma(epsilon float64, delta float64, l2 float64) float64 {
+	if l2 <= 0 {
+		return 0
+	}
+	private := func(sigma float64) bool {
+		a, b := l2/(2*sigma), epsilon*sigma/l2
+		// e^ε·Φ(−a−b) in logs, so a large ε can't overflow it
+		tail := math.Exp(epsilon + math.Log(normalCDF(-a-b)))
+		return normalCDF(a-b)-tail <= delta
+	}
+	hi := l2 * math.Sqrt(2*math.Log(1.25/delta)) / epsilon
+	for !private(hi) {
+		hi *= 2
+	}
+	lo := 0.0
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if private(mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return hi
+}
+
+// normalCDF is Φ, the standard normal distribution function.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// sampleGaussian draws Gaussian noise with standard deviation σ around
+// trueValue, landing on a grid of step γ like sampleLaplace so the result
+// doesn't give the true value away through its low bits.
+func sampleGaussian(trueValue float64, sigma float64, gamma float64, source NoiseSource) float64 {
+	if sigma <= 0 || gamma <= 0 {
+		return trueValue
+	}
+	// Box–Muller
+	z := math.Sqrt(-2*math.Log(uniformOpenClosed(source))) * math.Cos(2*math.Pi*uniformOpenClosed(source))
+	return (math.Round(trueValue/gamma) + math.Round(z*sigma/gamma)) * gamma
+}
@@ -0,0 +1,107 @@
+package dpsql
+
+import (
+	"math"
+	"testing"
+)
+
+const noiseSamples = 200000
+
+func TestSampleLaplaceScale(t *testing.T) {
+	source := NewSeededSource(1)
+	const b, sensitivity, trueValue = 4.0, 2.0, 100.0
+	gamma := math.Ldexp(1, math.Ilogb(b)-noiseGrid)
+
+	var sumAbs float64
+	for i := 0; i < noiseSamples; i++ {
+		v := sampleLaplace(trueValue, b, sensitivity, source)
+		if steps := v / gamma; steps != math.Trunc(steps) {
+			t.Fatalf("sample %v is off the grid of step %v", v, gamma)
+		}
+		sumAbs += math.Abs(v - trueValue)
+	}
+	// E|X| of Laplace noise is its scale, widened here for the grid
+	want := b * (sensitivity + gamma) / sensitivity
+	if got := sumAbs / noiseSamples; math.Abs(got-want) > 0.02*want {
+		t.Errorf("mean |noise| = %v, want about %v", got, want)
+	}
+}
+
+func TestSampleLaplaceWithoutScaleKeepsValue(t *testing.T) {
+	source := NewSeededSource(1)
+	for _, b := range []float64{0, -1, math.Inf(1), math.NaN()} {
+		if got := sampleLaplace(7.25, b, 1, source); got != 7.25 {
+			t.Errorf("sampleLaplace(7.25, b=%v) = %v, want 7.25", b, got)
+		}
+	}
+}
+
+func TestSampleGaussianScale(t *testing.T) {
+	source := NewSeededSource(2)
+	const sigma, trueValue = 3.0, -20.0
+	gamma := math.Ldexp(1, math.Ilogb(sigma)-noiseGrid)
+
+	var sum, sumSquares float64
+	for i := 0; i < noiseSamples; i++ {
+		v := sampleGaussian(trueValue, sigma, gamma, source)
+		if steps := v / gamma; steps != math.Trunc(steps) {
+			t.Fatalf("sample %v is off the grid of step %v", v, gamma)
+		}
+		sum += v - trueValue
+		sumSquares += (v - trueValue) * (v - trueValue)
+	}
+	mean := sum / noiseSamples
+	std := math.Sqrt(sumSquares/noiseSamples - mean*mean)
+	if math.Abs(mean) > 0.05 {
+		t.Errorf("mean noise = %v, want about 0", mean)
+	}
+	if math.Abs(std-sigma) > 0.02*sigma {
+		t.Errorf("noise σ = %v, want about %v", std, sigma)
+	}
+}
+
+func TestGaussianSigma(t *testing.T) {
+	// the analytic Gaussian mechanism's σ for ε = 1, δ = 1e-5 and Δ = 1
+	if got := gaussianSigma(1, 1e-5, 1); math.Abs(got-3.7306) > 1e-3 {
+		t.Errorf("gaussianSigma(1, 1e-5, 1) = %v, want 3.7306", got)
+	}
+	// σ grows with the sensitivity
+	one, two := gaussianSigma(0.5, 1e-6, 1), gaussianSigma(0.5, 1e-6, 2)
+	if math.Abs(two-2*one) > 1e-9*two {
+		t.Errorf("gaussianSigma for Δ = 2 is %v, want twice %v", two, one)
+	}
+	// and it is the smallest σ meeting the condition: a little less isn't
+	// private
+	for _, c := range []struct{ epsilon, delta float64 }{{0.1, 1e-5}, {1, 1e-5}, {5, 1e-7}} {
+		sigma := gaussianSigma(c.epsilon, c.delta, 1)
+		at := func(s float64) float64 {
+			a, b := 1/(2*s), c.epsilon*s
+			return normalCDF(a-b) - math.Exp(c.epsilon)*normalCDF(-a-b)
+		}
+		if at(sigma) > c.delta*(1+1e-6) || at(sigma*0.99) <= c.delta {
+			t.Errorf("gaussianSigma(%v, %v, 1) = %v is not the smallest private σ", c.epsilon, c.delta, sigma)
+		}
+	}
+	if got := gaussianSigma(1, 1e-5, 0); got != 0 {
+		t.Errorf("gaussianSigma with no sensitivity = %v, want 0", got)
+	}
+}
+
+func TestGaussianScaleNormalisesEachAggregate(t *testing.T) {
+	sigmas, gammas := gaussianScale(1, 1e-5, map[string]float64{"count": 1, "SUM(age)": 120, "none": 0})
+	if _, ok := sigmas["none"]; ok {
+		t.Errorf("an aggregate without sensitivity got σ %v", sigmas["none"])
+	}
+	if ratio := sigmas["SUM(age)"] / sigmas["count"]; math.Abs(ratio-120) > 1e-9 {
+		t.Errorf("σ of SUM(age) is %v times that of count, want 120", ratio)
+	}
+	// two aggregates share the budget, so each needs more than one alone
+	if alone := gaussianSigma(1, 1e-5, 1); sigmas["count"] <= alone {
+		t.Errorf("σ of count is %v, want more than %v for one aggregate", sigmas["count"], alone)
+	}
+	for col, gamma := range gammas {
+		if gamma <= 0 || gamma > sigmas[col]/500 {
+			t.Errorf("grid step for %s is %v, want a small fraction of σ %v", col, gamma, sigmas[col])
+		}
+	}
+}
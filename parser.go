@@ -1,72 +1,181 @@
-package main
+package dpsql
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 )
 
 // --- Helper for token type comparison ---
 
-func checkType(token *Token, _token_type TokenType) bool {
+func checkType(token *lexToken, _token_type tokenType) bool {
 	return token._type == _token_type
 }
 
-func panicIfWrongType(token *Token, _token_type TokenType) {
+// panicIfWrongType stops the parse with a ParseError unless token has the
+// expected type. context says where in the statement it was expected, e.g.
+// "after VARCHAR length", and may be empty.
+func panicIfWrongType(token *lexToken, _token_type tokenType, context string) {
 	// fmt.Println("Token value:", token.value)
 	// fmt.Println("Token type:", tokenTypeToString(token._type))
 	// fmt.Println("Expected type:", tokenTypeToString(_token_type))
 	if !checkType(token, _token_type) {
-		panic("Wrong token type")
+		if context != "" {
+			context = " " + context
+		}
+		parseErrorAt(token, "expected %s%s, got %s", describeTokenType(_token_type), context, describeToken(token))
+	}
+}
+
+// ParseError is a syntax error at a line and column of the input.
+type ParseError struct {
+	Line    int
+	Col     int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, col %d: %s", e.Line, e.Col, e.Message)
+}
+
+// parseErrorAt stops the parse with a ParseError at token. parseCommands
+// recovers it and moves on to the next statement.
+func parseErrorAt(token *lexToken, format string, args ...interface{}) {
+	panic(&ParseError{Line: token.line, Col: token.col, Message: fmt.Sprintf(format, args...)})
+}
+
+// expectStatementEnd ends a statement, which must be followed by ';' (moved
+// past) or the end of the input. Anything else is an error, so that a
+// statement the parser only understood part of never runs cut short.
+func expectStatementEnd(tokens []*lexToken, tokenIndex *int, statement string) {
+	switch {
+	case checkType(tokens[*tokenIndex], tokenSemicolon):
+		(*tokenIndex)++ // Move past SEMICOLON
+	case checkType(tokens[*tokenIndex], tokenEOF):
+	default:
+		parseErrorAt(tokens[*tokenIndex], "expected ';' to end %s, got %s", statement, describeToken(tokens[*tokenIndex]))
+	}
+}
+
+// describeTokenType names a token type for an error message: punctuation as
+// the character itself, everything else by its type name.
+func describeTokenType(t tokenType) string {
+	switch t {
+	case tokenEOF:
+		return "end of input"
+	case tokenDot:
+		return "'.'"
+	case tokenStar:
+		return "'*'"
+	case tokenComma:
+		return "','"
+	case tokenSemicolon:
+		return "';'"
+	case tokenLParen:
+		return "'('"
+	case tokenRParen:
+		return "')'"
+	case tokenSingleQuote:
+		return `"'"`
+	case tokenDoubleQuote:
+		return `'"'`
+	case tokenEquals:
+		return "'='"
+	case tokenPlus:
+		return "'+'"
+	case tokenMinus:
+		return "'-'"
+	case tokenSlash:
+		return "'/'"
+	case tokenPercent:
+		return "'%'"
+	case tokenNotEquals:
+		return "'<>'"
+	case tokenLessThan:
+		return "'<'"
+	case tokenGreaterThan:
+		return "'>'"
+	case tokenLessEqual:
+		return "'<='"
+	case tokenGreaterEqual:
+		return "'>='"
+	}
+	return tokenTypeToString(t)
+}
+
+// describeToken is describeTokenType for a token that was found, adding what
+// the lexer couldn't make sense of.
+func describeToken(token *lexToken) string {
+	if token._type == tokenIllegal {
+		return fmt.Sprintf("ILLEGAL (%s)", token.value)
+	}
+	return describeTokenType(token._type)
+}
+
+// isPunctuation reports whether a token can't stand for a name or a value.
+func isPunctuation(token *lexToken) bool {
+	switch token._type {
+	case tokenEOF, tokenIllegal, tokenDot, tokenComma, tokenSemicolon,
+		tokenLParen, tokenRParen, tokenEquals:
+		return true
 	}
+	return false
 }
 
 // --- AST definitions ---
 
-type ASTNodeType int
+type astNodeType int
 
 const (
-	AST_INT_LITERAL ASTNodeType = iota
-	AST_VARCHAR_LITERAL
-	AST_BOOLEAN_LITERAL
-	AST_FLOAT_LITERAL
-	AST_SELECT
-	AST_CREATE
-	AST_INSERT
-	AST_FUNCTION
-	AST_EXPRESSION
-	AST_VALUE
-	AST_COLUMN_NAME
-	AST_BINARY
-	AST_COLUMN
+	astIntLiteral astNodeType = iota
+	astVarcharLiteral
+	astBooleanLiteral
+	astFloatLiteral
+	astSelect
+	astCreate
+	astInsert
+	astFunction
+	astExpression
+	astValue
+	astColumnName
+	astBinary
+	astColumn
+	astUnary
+	astJoin
+	astUpdate
+	astDelete
+	astTruncate
+	astShowBudget
+	astDrop
+	astAlter
+	astTransaction
 )
 
 type columnType int
 
 const (
-	COLUMN_TYPE_NOT_INCLUDED columnType = iota
-	COLUMN_TYPE_GROUP_BY
-	COLUMN_TYPE_NORMAL
-	COLUMN_TYPE_MAX
-	COLUMN_TYPE_MIN
-	COLUMN_TYPE_AVG
-	COLUMN_TYPE_COUNT
-	COLUMN_TYPE_SUM
+	columnTypeNotIncluded columnType = iota
+	columnTypeGroupBy
+	columnTypeNormal
+	columnTypeMax
+	columnTypeMin
+	columnTypeAvg
+	columnTypeCount
+	columnTypeSum
 )
 
-type ASTNode struct {
+type astNode struct {
 	// Common fields
-	Type      ASTNodeType
-	IntVal    int    // (unused)
-	StrVal    string // (unused)
+	Type      astNodeType
 	floatVal  float64
 	boolValue bool
 
 	// Expression node (for identifiers and literals)
 	columnName string
 
-	left     *ASTNode
-	right    *ASTNode
+	left     *astNode
+	right    *astNode
 	operator string
 
 	// For literal numbers and strings (alternative fields)
@@ -75,7 +184,7 @@ type ASTNode struct {
 
 	// Function node
 	functionName       string
-	functionArguements []*ASTNode
+	functionArguements []*astNode
 
 	// Column node (used in CREATE)
 	name         string
@@ -87,205 +196,370 @@ type ASTNode struct {
 	columnNames   []string
 	columnTypes   []columnType
 	columnAliases []string
+	columnHidden  []bool // aggregates only HAVING/ORDER BY need, computed but not shown
 
 	containsGroupBy bool
 	groupByColumns  []string
+	whereClause     *astNode
+	havingClause    *astNode
+	orderBy         []orderByTerm
 	containsLimit   bool
 	limit           int
 	containsOffset  bool
 	offset          int
 
-	// Create node
-	tableName string
-	columns   []*ASTNode
+	// From clause (select) / join node
+	tableAlias string
+	joins      []*astNode
+	joinType   string
+	onClause   *astNode
+
+	// Create node (column-level REFERENCES land in the column's foreignKeys)
+	tableName   string
+	columns     []*astNode
+	foreignKeys []foreignKey
 
 	// Insert node
-	columnValues []string
+	columnValues []interface{} // a value's text, or nil for NULL
+
+	// Update node (targets are in columnNames, filter in whereClause)
+	setValues []*astNode
+
+	// Drop / alter node (ADD COLUMN puts its definition in columns[0])
+	ifExists    bool
+	alterAction string
+	newName     string
+
+	// Transaction node: "BEGIN", "COMMIT", "ROLLBACK", "SAVEPOINT",
+	// "ROLLBACK TO" or "RELEASE", with the savepoint in name
+	transactionAction string
+
+	// Statement nodes: byte range of the statement in the parsed input
+	sourceStart int
+	sourceEnd   int
+}
+
+// orderByTerm is one key of an ORDER BY clause.
+type orderByTerm struct {
+	expr       *astNode
+	descending bool
+	nullsFirst bool
 }
 
 // --- Functions used by parser ---
 
-func endExpressionValues(token *Token) bool {
-	return token._type == TOKEN_COMMA ||
-		token._type == TOKEN_FROM ||
-		token._type == TOKEN_AS ||
-		token._type == TOKEN_RPAREN
+func isComparisonOperator(token *lexToken) bool {
+	return token._type == tokenEquals ||
+		token._type == tokenNotEquals ||
+		token._type == tokenLessThan ||
+		token._type == tokenGreaterThan ||
+		token._type == tokenLessEqual ||
+		token._type == tokenGreaterEqual
 }
 
-func functionValues(token *Token) bool {
-	return token._type == TOKEN_COUNT ||
-		token._type == TOKEN_SUM ||
-		token._type == TOKEN_AVG ||
-		token._type == TOKEN_MIN ||
-		token._type == TOKEN_MAX
+func functionValues(token *lexToken) bool {
+	return token._type == tokenCount ||
+		token._type == tokenSum ||
+		token._type == tokenAvg ||
+		token._type == tokenMin ||
+		token._type == tokenMax
 }
 
 // --- Parsing functions ---
 
-func parseExpression(tokens []*Token, tokenIndex *int) *ASTNode {
-	// Handle function expressions first.
+// parseExpression parses an expression with the usual SQL precedence, from
+// loosest to tightest: OR, AND, NOT, comparisons, + and -, then * / and %.
+func parseExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	return parseOrExpression(tokens, tokenIndex)
+}
+
+func parseOrExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	leftNode := parseAndExpression(tokens, tokenIndex)
+	for checkType(tokens[*tokenIndex], tokenOr) {
+		(*tokenIndex)++ // Move past OR
+		leftNode = &astNode{
+			Type:     astBinary,
+			left:     leftNode,
+			operator: "OR",
+			right:    parseAndExpression(tokens, tokenIndex),
+		}
+	}
+	return leftNode
+}
+
+func parseAndExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	leftNode := parseNotExpression(tokens, tokenIndex)
+	for checkType(tokens[*tokenIndex], tokenAnd) {
+		(*tokenIndex)++ // Move past AND
+		leftNode = &astNode{
+			Type:     astBinary,
+			left:     leftNode,
+			operator: "AND",
+			right:    parseNotExpression(tokens, tokenIndex),
+		}
+	}
+	return leftNode
+}
+
+func parseNotExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	if checkType(tokens[*tokenIndex], tokenNot) {
+		(*tokenIndex)++ // Move past NOT
+		return &astNode{
+			Type:     astUnary,
+			operator: "NOT",
+			left:     parseNotExpression(tokens, tokenIndex),
+		}
+	}
+	return parseComparisonExpression(tokens, tokenIndex)
+}
+
+func parseComparisonExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	leftNode := parseAdditiveExpression(tokens, tokenIndex)
+
+	if isComparisonOperator(tokens[*tokenIndex]) {
+		operator := tokens[*tokenIndex].value
+		(*tokenIndex)++ // Move past the operator.
+		return &astNode{
+			Type:     astBinary,
+			left:     leftNode,
+			operator: operator,
+			right:    parseAdditiveExpression(tokens, tokenIndex),
+		}
+	}
+
+	// x IS [NOT] NULL
+	if checkType(tokens[*tokenIndex], tokenIs) {
+		(*tokenIndex)++ // Move past IS
+		operator := "IS NULL"
+		if checkType(tokens[*tokenIndex], tokenNot) {
+			operator = "IS NOT NULL"
+			(*tokenIndex)++ // Move past NOT
+		}
+		panicIfWrongType(tokens[*tokenIndex], tokenNull, "after IS")
+		(*tokenIndex)++ // Move past NULL
+		return &astNode{
+			Type:     astUnary,
+			operator: operator,
+			left:     leftNode,
+		}
+	}
+
+	return leftNode
+}
+
+func parseAdditiveExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	leftNode := parseMultiplicativeExpression(tokens, tokenIndex)
+	for checkType(tokens[*tokenIndex], tokenPlus) || checkType(tokens[*tokenIndex], tokenMinus) {
+		operator := tokens[*tokenIndex].value
+		(*tokenIndex)++ // Move past the operator.
+		leftNode = &astNode{
+			Type:     astBinary,
+			left:     leftNode,
+			operator: operator,
+			right:    parseMultiplicativeExpression(tokens, tokenIndex),
+		}
+	}
+	return leftNode
+}
+
+func parseMultiplicativeExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	leftNode := parsePrimaryExpression(tokens, tokenIndex)
+	for checkType(tokens[*tokenIndex], tokenStar) || checkType(tokens[*tokenIndex], tokenSlash) || checkType(tokens[*tokenIndex], tokenPercent) {
+		operator := tokens[*tokenIndex].value
+		(*tokenIndex)++ // Move past the operator.
+		leftNode = &astNode{
+			Type:     astBinary,
+			left:     leftNode,
+			operator: operator,
+			right:    parsePrimaryExpression(tokens, tokenIndex),
+		}
+	}
+	return leftNode
+}
+
+func parsePrimaryExpression(tokens []*lexToken, tokenIndex *int) *astNode {
+	// Parenthesised sub-expression.
+	if checkType(tokens[*tokenIndex], tokenLParen) {
+		(*tokenIndex)++ // Move past LPAREN
+		inner := parseExpression(tokens, tokenIndex)
+		panicIfWrongType(tokens[*tokenIndex], tokenRParen, "to close the parenthesis")
+		(*tokenIndex)++ // Move past RPAREN
+		return inner
+	}
+
+	// Handle function expressions.
 	if functionValues(tokens[*tokenIndex]) {
 		newFunctionName := tokens[*tokenIndex].value
 		(*tokenIndex)++ // Move past function name
-		panicIfWrongType(tokens[*tokenIndex], TOKEN_LPAREN)
+		panicIfWrongType(tokens[*tokenIndex], tokenLParen, "after function name")
 		(*tokenIndex)++ // Move past LPAREN
-		var newFunctionArguements []*ASTNode = make([]*ASTNode, 0)
-		for !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-			newFunctionArguements = append(newFunctionArguements, parseExpression(tokens, tokenIndex))
+		var newFunctionArguements []*astNode = make([]*astNode, 0)
+		for !checkType(tokens[*tokenIndex], tokenRParen) {
+			if checkType(tokens[*tokenIndex], tokenStar) {
+				newFunctionArguements = append(newFunctionArguements, &astNode{Type: astColumnName, columnName: "*"})
+				(*tokenIndex)++ // Move past *
+			} else {
+				newFunctionArguements = append(newFunctionArguements, parseExpression(tokens, tokenIndex))
+			}
+			if checkType(tokens[*tokenIndex], tokenComma) {
+				(*tokenIndex)++ // Move past comma
+			}
 		}
 		(*tokenIndex)++ // Move past RPAREN
-		expressionNodeForFunction := ASTNode{
-			Type:               AST_FUNCTION,
+		return &astNode{
+			Type:               astFunction,
 			functionName:       newFunctionName,
 			functionArguements: newFunctionArguements,
 		}
-		if !endExpressionValues(tokens[*tokenIndex]) {
-			(*tokenIndex)++ // Move past operator
-			return &ASTNode{
-				Type:     AST_BINARY,
-				left:     &expressionNodeForFunction,
-				operator: tokens[(*tokenIndex)-1].value,
-				right:    parseExpression(tokens, tokenIndex),
-			}
-		} else {
-			return &expressionNodeForFunction
-		}
 	}
 
-	var leftNode *ASTNode = nil
+	var leftNode *astNode = nil
 
 	// Check for various literal types.
-	if tokens[*tokenIndex]._type == TOKEN_INT_LITERAL {
-		val, _ := strconv.Atoi(tokens[*tokenIndex].value)
-		leftNode = &ASTNode{
-			Type:   AST_INT_LITERAL,
-			intVal: int64(val),
-		}
-	} else if tokens[*tokenIndex]._type == TOKEN_VARCHAR_LITERAL {
-		leftNode = &ASTNode{
-			Type:   AST_VARCHAR_LITERAL,
+	if tokens[*tokenIndex]._type == tokenIntLiteral {
+		val, err := strconv.ParseInt(tokens[*tokenIndex].value, 10, 64)
+		if err != nil {
+			parseErrorAt(tokens[*tokenIndex], "invalid number %s", tokens[*tokenIndex].value)
+		}
+		leftNode = &astNode{
+			Type:   astIntLiteral,
+			intVal: val,
+		}
+	} else if tokens[*tokenIndex]._type == tokenVarcharLiteral {
+		leftNode = &astNode{
+			Type:   astVarcharLiteral,
 			strVal: tokens[*tokenIndex].value,
 		}
-	} else if tokens[*tokenIndex]._type == TOKEN_FLOAT_LITERAL {
+	} else if tokens[*tokenIndex]._type == tokenFloatLiteral {
 		f, err := strconv.ParseFloat(tokens[*tokenIndex].value, 64)
 		if err != nil {
-			panic(err)
+			parseErrorAt(tokens[*tokenIndex], "invalid number %s", tokens[*tokenIndex].value)
 		}
-		leftNode = &ASTNode{
-			Type:     AST_FLOAT_LITERAL,
+		leftNode = &astNode{
+			Type:     astFloatLiteral,
 			floatVal: f,
 		}
-	} else if tokens[*tokenIndex]._type == TOKEN_BOOLEAN_LITERAL {
+	} else if tokens[*tokenIndex]._type == tokenBooleanLiteral {
 		b, err := strconv.ParseBool(tokens[*tokenIndex].value)
 		if err != nil {
-			panic(err)
+			parseErrorAt(tokens[*tokenIndex], "invalid boolean %s", tokens[*tokenIndex].value)
 		}
-		leftNode = &ASTNode{
-			Type:      AST_BOOLEAN_LITERAL,
+		leftNode = &astNode{
+			Type:      astBooleanLiteral,
 			boolValue: b,
 		}
-	} else {
-		// Otherwise, treat it as a column name.
-		leftNode = &ASTNode{
-			Type:       AST_COLUMN_NAME,
-			columnName: tokens[*tokenIndex].value,
+	} else if tokens[*tokenIndex]._type == tokenTrue || tokens[*tokenIndex]._type == tokenFalse {
+		leftNode = &astNode{
+			Type:      astBooleanLiteral,
+			boolValue: tokens[*tokenIndex]._type == tokenTrue,
 		}
-	}
-	(*tokenIndex)++ // Move past literal or identifier.
-
-	if endExpressionValues(tokens[*tokenIndex]) {
-		return leftNode
 	} else {
-		operator := tokens[*tokenIndex].value
-		(*tokenIndex)++ // Move past the operator.
-		return &ASTNode{
-			Type:     AST_BINARY,
-			left:     leftNode,
-			operator: operator,
-			right:    parseExpression(tokens, tokenIndex),
+		// Otherwise, treat it as a (possibly qualified) column name.
+		return &astNode{
+			Type:       astColumnName,
+			columnName: parseColumnReference(tokens, tokenIndex),
 		}
 	}
-	// Unreachable, but required for function completeness.
-	return nil
+	(*tokenIndex)++ // Move past literal.
+
+	return leftNode
 }
 
-func isTokenSELECTSpliter(tokens []*Token, tokenIndex *int) bool {
+func isTokenSELECTSpliter(tokens []*lexToken, tokenIndex *int) bool {
 	t := tokens[*tokenIndex]._type
-	return t == TOKEN_FROM || t == TOKEN_AS || t == TOKEN_RPAREN ||
-		t == TOKEN_COMMA || t == TOKEN_SEMICOLON
+	return t == tokenFrom || t == tokenAs || t == tokenRParen ||
+		t == tokenComma || t == tokenSemicolon || t == tokenEOF ||
+		t == tokenHaving || t == tokenOrder || t == tokenLimit || t == tokenOffset
 }
 
-func checkTokenIsFunction(token *Token) bool {
-	if token._type == TOKEN_MAX || token._type == TOKEN_MIN || token._type == TOKEN_AVG || token._type == TOKEN_SUM || token._type == TOKEN_COUNT {
+func checkTokenIsFunction(token *lexToken) bool {
+	if token._type == tokenMax || token._type == tokenMin || token._type == tokenAvg || token._type == tokenSum || token._type == tokenCount {
 		return true
 	}
 	return false
 }
 
-func tokenToColumnType(token *Token) columnType {
+func tokenToColumnType(token *lexToken) columnType {
 	switch token._type {
-	case TOKEN_MAX:
-		return COLUMN_TYPE_MAX
-	case TOKEN_MIN:
-
-		return COLUMN_TYPE_MIN
-	case TOKEN_AVG:
-
-		return COLUMN_TYPE_AVG
-	case TOKEN_SUM:
-		return COLUMN_TYPE_SUM
-	case TOKEN_COUNT:
-		return COLUMN_TYPE_COUNT
+	case tokenMax:
+		return columnTypeMax
+	case tokenMin:
+
+		return columnTypeMin
+	case tokenAvg:
+
+		return columnTypeAvg
+	case tokenSum:
+		return columnTypeSum
+	case tokenCount:
+		return columnTypeCount
+	default:
+		parseErrorAt(token, "expected an aggregate, got %s", describeToken(token))
+		return 0
+	}
+}
+
+func functionNameToColumnType(functionName string) columnType {
+	switch strings.ToUpper(functionName) {
+	case "MAX":
+		return columnTypeMax
+	case "MIN":
+		return columnTypeMin
+	case "AVG":
+		return columnTypeAvg
+	case "SUM":
+		return columnTypeSum
+	case "COUNT":
+		return columnTypeCount
 	default:
-		panic("Unknown token type")
+		panic("Unknown function " + functionName)
 	}
 }
 
-func parseSelectCommand(tokens []*Token, tokenIndex *int) *ASTNode {
+func parseSelectCommand(tokens []*lexToken, tokenIndex *int) *astNode {
 	// fmt.Println("Starting parseSelectCommand")
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_SELECT)
+	panicIfWrongType(tokens[*tokenIndex], tokenSelect, "")
 	// fmt.Println("Matched SELECT token")
 	(*tokenIndex)++
 
-	selectNode := ASTNode{Type: AST_SELECT}
-	selectNode.columns = make([]*ASTNode, 0)
+	selectNode := astNode{Type: astSelect}
+	selectNode.columns = make([]*astNode, 0)
 	selectNode.columnNames = make([]string, 0)
 	selectNode.columnAliases = make([]string, 0)
 	selectNode.columnTypes = make([]columnType, 0)
+	selectNode.columnHidden = make([]bool, 0)
 
-	if tokens[*tokenIndex]._type == TOKEN_STAR {
-		fmt.Println("Found * token — SELECT * not yet implemented")
-		// Go through each one (not implemented here)
+	if tokens[*tokenIndex]._type == tokenStar {
+		parseErrorAt(tokens[*tokenIndex], "SELECT * is not supported, list the columns instead")
 	} else {
 		// fmt.Println("Parsing SELECT column list...")
-		for tokens[*tokenIndex]._type != TOKEN_FROM {
+		for tokens[*tokenIndex]._type != tokenFrom {
 			if checkTokenIsFunction(tokens[*tokenIndex]) {
 				// fmt.Printf("Found function: %s\n", tokens[*tokenIndex].value)
 				selectNode.columnTypes = append(selectNode.columnTypes, tokenToColumnType(tokens[*tokenIndex]))
 
 				(*tokenIndex)++
 
-				panicIfWrongType(tokens[*tokenIndex], TOKEN_LPAREN)
+				panicIfWrongType(tokens[*tokenIndex], tokenLParen, "after aggregate name")
 				// fmt.Println("Matched LPAREN after function")
 				(*tokenIndex)++
 
-				selectNode.columnNames = append(selectNode.columnNames, tokens[*tokenIndex].value)
+				selectNode.columnNames = append(selectNode.columnNames, parseColumnReference(tokens, tokenIndex))
 				// fmt.Printf("Added function argument column: %s\n", tokens[*tokenIndex].value)
-				(*tokenIndex)++
 
-				panicIfWrongType(tokens[*tokenIndex], TOKEN_RPAREN)
+				panicIfWrongType(tokens[*tokenIndex], tokenRParen, "after aggregate argument")
 				// fmt.Println("Matched RPAREN after function argument")
 				(*tokenIndex)++
 			} else {
 				// fmt.Printf("Found regular column: %s\n", tokens[*tokenIndex].value)
-				selectNode.columnNames = append(selectNode.columnNames, tokens[*tokenIndex].value)
-				selectNode.columnTypes = append(selectNode.columnTypes, COLUMN_TYPE_NORMAL)
-				(*tokenIndex)++
+				selectNode.columnNames = append(selectNode.columnNames, parseColumnReference(tokens, tokenIndex))
+				selectNode.columnTypes = append(selectNode.columnTypes, columnTypeNormal)
 			}
 
-			if tokens[*tokenIndex]._type == TOKEN_AS {
+			if tokens[*tokenIndex]._type == tokenAs {
 				(*tokenIndex)++
+				panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after AS")
 				selectNode.columnAliases = append(selectNode.columnAliases, tokens[*tokenIndex].value)
 				// fmt.Printf("Added alias: %s\n", tokens[*tokenIndex].value)
 				(*tokenIndex)++
@@ -293,44 +567,52 @@ func parseSelectCommand(tokens []*Token, tokenIndex *int) *ASTNode {
 				selectNode.columnAliases = append(selectNode.columnAliases, "") //append(selectNode.columnAliases, selectNode.columnNames[len(selectNode.columnNames)-1])
 				// fmt.Printf("No alias, using column name as alias: %s\n", selectNode.columnNames[len(selectNode.columnNames)-1])
 			}
+			selectNode.columnHidden = append(selectNode.columnHidden, false)
 
-			if tokens[*tokenIndex]._type == TOKEN_COMMA {
+			if tokens[*tokenIndex]._type == tokenComma {
 				// fmt.Println("Found comma, moving to next column")
 				(*tokenIndex)++
 			}
 		}
 	}
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_FROM)
+	panicIfWrongType(tokens[*tokenIndex], tokenFrom, "after SELECT list")
 	// fmt.Println("Matched FROM token")
 	(*tokenIndex)++
 
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after FROM")
 	selectNode.tableName = tokens[*tokenIndex].value
 	// fmt.Printf("Set table name: %s\n", selectNode.tableName)
 	(*tokenIndex)++
+	selectNode.tableAlias = parseTableAlias(tokens, tokenIndex)
 
-	if tokens[*tokenIndex]._type == TOKEN_GROUP {
+	for isJoinStart(tokens[*tokenIndex]) {
+		selectNode.joins = append(selectNode.joins, parseJoin(tokens, tokenIndex))
+	}
+
+	if checkType(tokens[*tokenIndex], tokenWhere) {
+		(*tokenIndex)++ // Move past WHERE
+		selectNode.whereClause = parseExpression(tokens, tokenIndex)
+	}
+
+	if tokens[*tokenIndex]._type == tokenGroup {
 		// fmt.Println("Found GROUP token")
 		(*tokenIndex)++
 
-		panicIfWrongType(tokens[*tokenIndex], TOKEN_BY)
+		panicIfWrongType(tokens[*tokenIndex], tokenBy, "after GROUP")
 		// fmt.Println("Matched BY token after GROUP")
 		(*tokenIndex)++
 
 		// fmt.Println("Parsing GROUP BY columns...")
-		for !isTokenSELECTSpliter(tokens, tokenIndex) {
-			if tokens[*tokenIndex]._type == TOKEN_COMMA {
-				// fmt.Println("Skipping comma in GROUP BY")
-				(*tokenIndex)++
-			}
-
-			col := tokens[*tokenIndex].value
+		for {
+			colToken := tokens[*tokenIndex]
+			col := parseColumnReference(tokens, tokenIndex)
 			// fmt.Printf("Checking GROUP BY column: %s\n", col)
 			matched := false
 
 			for i, name := range selectNode.columnNames {
 				if name == col {
-					selectNode.columnTypes[i] = COLUMN_TYPE_GROUP_BY
+					selectNode.columnTypes[i] = columnTypeGroupBy
 					// fmt.Printf("Marked column %s as GROUP BY\n", col)
 					matched = true
 					break
@@ -338,25 +620,62 @@ func parseSelectCommand(tokens []*Token, tokenIndex *int) *ASTNode {
 			}
 
 			if !matched {
-				// fmt.Println("Current SELECT column names:")
-				for _, name := range selectNode.columnNames {
-					fmt.Printf(" - %s\n", name)
-				}
-				// panic(fmt.Sprintf("GROUP BY column %q not in S/ELECT list", col))
+				parseErrorAt(colToken, "GROUP BY column %s is not in the SELECT list", col)
 			}
 
-			(*tokenIndex)++
+			// isTokenSELECTSpliter stops at commas, so the list is walked here
+			if !checkType(tokens[*tokenIndex], tokenComma) {
+				break
+			}
+			(*tokenIndex)++ // Move past COMMA
+		}
+	}
+
+	if checkType(tokens[*tokenIndex], tokenHaving) {
+		(*tokenIndex)++ // Move past HAVING
+		selectNode.havingClause = parseExpression(tokens, tokenIndex)
+		addHiddenAggregates(&selectNode, selectNode.havingClause)
+	}
+
+	if checkType(tokens[*tokenIndex], tokenOrder) {
+		(*tokenIndex)++ // Move past ORDER
+		panicIfWrongType(tokens[*tokenIndex], tokenBy, "after ORDER")
+		(*tokenIndex)++ // Move past BY
+		selectNode.orderBy = parseOrderByTerms(tokens, tokenIndex)
+		for _, term := range selectNode.orderBy {
+			addHiddenAggregates(&selectNode, term.expr)
 		}
 	}
 
-	fmt.Println("Finished parseSelectCommand successfully")
+	// LIMIT and OFFSET may come in either order.
+	for checkType(tokens[*tokenIndex], tokenLimit) || checkType(tokens[*tokenIndex], tokenOffset) {
+		isLimit := checkType(tokens[*tokenIndex], tokenLimit)
+		(*tokenIndex)++ // Move past LIMIT/OFFSET
+		panicIfWrongType(tokens[*tokenIndex], tokenIntLiteral, "after LIMIT or OFFSET")
+		n, err := strconv.Atoi(tokens[*tokenIndex].value)
+		if err != nil || n < 0 {
+			parseErrorAt(tokens[*tokenIndex], "invalid count %s", tokens[*tokenIndex].value)
+		}
+		if isLimit {
+			selectNode.containsLimit = true
+			selectNode.limit = n
+		} else {
+			selectNode.containsOffset = true
+			selectNode.offset = n
+		}
+		(*tokenIndex)++ // Move past the count
+	}
+
+	expectStatementEnd(tokens, tokenIndex, "SELECT")
+
+	// fmt.Println("Finished parseSelectCommand successfully")
 	return &selectNode
 }
 
 // expectMore := true
 // for expectMore {
 // 	selectNode.columns = append(selectNode.columns, parseExpression(tokens, tokenIndex))
-// 	if checkType(tokens[*tokenIndex], TOKEN_AS) {
+// 	if checkType(tokens[*tokenIndex], tokenAs) {
 // 		(*tokenIndex)++ // Move past AS token
 // 		selectNode.columnNames = append(selectNode.columnNames, tokens[*tokenIndex].value)
 // 		(*tokenIndex)++
@@ -364,28 +683,28 @@ func parseSelectCommand(tokens []*Token, tokenIndex *int) *ASTNode {
 // 		selectNode.columnNames = append(selectNode.columnNames, "")
 // 	}
 
-// 	if checkType(tokens[*tokenIndex], TOKEN_COMMA) {
+// 	if checkType(tokens[*tokenIndex], tokenComma) {
 // 		(*tokenIndex)++ // Move past comma and keep looping
 // 	} else {
 // 		expectMore = false
 // 	}
 // }
 
-// panicIfWrongType(tokens[*tokenIndex], TOKEN_FROM)
+// panicIfWrongType(tokens[*tokenIndex], tokenFrom)
 // (*tokenIndex)++ // Move past FROM token
 // selectNode.tableName = tokens[*tokenIndex].value
 // (*tokenIndex)++ // Move past name token
-// if checkType(tokens[*tokenIndex], TOKEN_WHERE) {
+// if checkType(tokens[*tokenIndex], tokenWhere) {
 
 // }
 
 // fmt.Println("HERERWEWRE")
 // fmt.Println(tokens[*tokenIndex].value)
 
-// if checkType(tokens[*tokenIndex], TOKEN_GROUP) {
+// if checkType(tokens[*tokenIndex], tokenGroup) {
 // 	(*tokenIndex)++
 // 	fmt.Println("HERERWEWRE")
-// 	if checkType(tokens[*tokenIndex], TOKEN_BY) {
+// 	if checkType(tokens[*tokenIndex], tokenBy) {
 // 		fmt.Println("HERERWEWRE")
 // 		selectNode.containsGroupBy = true
 // 		(*tokenIndex)++
@@ -395,7 +714,7 @@ func parseSelectCommand(tokens []*Token, tokenIndex *int) *ASTNode {
 // 			if isTokenSELECTSpliter(tokens, tokenIndex) {
 // 				break
 // 			}
-// 			panicIfWrongType(tokens[*tokenIndex], TOKEN_COMMA)
+// 			panicIfWrongType(tokens[*tokenIndex], tokenComma)
 // 			(*tokenIndex)++ // Move past comma
 // 		}
 // 	} else {
@@ -403,243 +722,954 @@ func parseSelectCommand(tokens []*Token, tokenIndex *int) *ASTNode {
 // 	}
 // }
 
-// if checkType(tokens[*tokenIndex], TOKEN_HAVING) {
+// if checkType(tokens[*tokenIndex], tokenHaving) {
 
 // }
 
-// if checkType(tokens[*tokenIndex], TOKEN_ORDER) {
-// 	if checkType(tokens[*tokenIndex], TOKEN_BY) {
+// if checkType(tokens[*tokenIndex], tokenOrder) {
+// 	if checkType(tokens[*tokenIndex], tokenBy) {
 
 // 	} else {
 // 		panic("Expected by")
 // 	}
 // }
-// if checkType(tokens[*tokenIndex], TOKEN_LIMIT) {
+// if checkType(tokens[*tokenIndex], tokenLimit) {
 
 // }
-// if checkType(tokens[*tokenIndex], TOKEN_OFFSET) {
+// if checkType(tokens[*tokenIndex], tokenOffset) {
 
 // }
 
-func parseInsertCommand(tokens []*Token, tokenIndex *int) *ASTNode {
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_INSERT)
+// parseColumnReference parses `column` or `table.column` and returns it as a
+// single name, which is also the key the column has in joined rows.
+func parseColumnReference(tokens []*lexToken, tokenIndex *int) string {
+	if isPunctuation(tokens[*tokenIndex]) {
+		parseErrorAt(tokens[*tokenIndex], "expected a column name, got %s", describeToken(tokens[*tokenIndex]))
+	}
+	name := tokens[*tokenIndex].value
+	(*tokenIndex)++ // Move past name
+	if checkType(tokens[*tokenIndex], tokenDot) {
+		(*tokenIndex)++ // Move past DOT
+		if isPunctuation(tokens[*tokenIndex]) {
+			parseErrorAt(tokens[*tokenIndex], "expected a column name after '.', got %s", describeToken(tokens[*tokenIndex]))
+		}
+		name += "." + tokens[*tokenIndex].value
+		(*tokenIndex)++ // Move past column name
+	}
+	return name
+}
+
+// parseTableAlias parses an optional `[AS] alias` after a table name.
+func parseTableAlias(tokens []*lexToken, tokenIndex *int) string {
+	if checkType(tokens[*tokenIndex], tokenAs) {
+		(*tokenIndex)++ // Move past AS
+		panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after AS")
+	}
+	if checkType(tokens[*tokenIndex], tokenIdentifier) {
+		alias := tokens[*tokenIndex].value
+		(*tokenIndex)++ // Move past alias
+		return alias
+	}
+	return ""
+}
+
+func isJoinStart(token *lexToken) bool {
+	return token._type == tokenJoin ||
+		token._type == tokenInner ||
+		token._type == tokenLeft ||
+		token._type == tokenRight ||
+		token._type == tokenFull
+}
+
+// parseJoin parses `[INNER | LEFT | RIGHT | FULL] [OUTER] JOIN table [alias] ON expr`.
+func parseJoin(tokens []*lexToken, tokenIndex *int) *astNode {
+	joinNode := astNode{Type: astJoin, joinType: "INNER"}
+	switch tokens[*tokenIndex]._type {
+	case tokenInner:
+		(*tokenIndex)++ // Move past INNER
+	case tokenLeft, tokenRight, tokenFull:
+		joinNode.joinType = strings.ToUpper(tokens[*tokenIndex].value)
+		(*tokenIndex)++ // Move past LEFT/RIGHT/FULL
+		if checkType(tokens[*tokenIndex], tokenOuter) {
+			(*tokenIndex)++ // Move past OUTER
+		}
+	}
+	panicIfWrongType(tokens[*tokenIndex], tokenJoin, "after join type")
+	(*tokenIndex)++ // Move past JOIN
+
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after JOIN")
+	joinNode.tableName = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Move past table name
+	joinNode.tableAlias = parseTableAlias(tokens, tokenIndex)
+
+	panicIfWrongType(tokens[*tokenIndex], tokenOn, "after joined table")
+	(*tokenIndex)++ // Move past ON
+	joinNode.onClause = parseExpression(tokens, tokenIndex)
+	return &joinNode
+}
+
+// addHiddenAggregates makes sure every aggregate HAVING or ORDER BY refers to
+// is computed, adding the ones missing from the SELECT list as hidden columns.
+func addHiddenAggregates(selectNode *astNode, expr *astNode) {
+	switch expr.Type {
+	case astFunction:
+		if len(expr.functionArguements) != 1 || expr.functionArguements[0].Type != astColumnName {
+			panic("Aggregates in HAVING and ORDER BY must take a single column")
+		}
+		ct := functionNameToColumnType(expr.functionName)
+		name := expr.functionArguements[0].columnName
+		for i := range selectNode.columnNames {
+			if selectNode.columnTypes[i] == ct && selectNode.columnNames[i] == name {
+				return
+			}
+		}
+		selectNode.columnNames = append(selectNode.columnNames, name)
+		selectNode.columnTypes = append(selectNode.columnTypes, ct)
+		selectNode.columnAliases = append(selectNode.columnAliases, "")
+		selectNode.columnHidden = append(selectNode.columnHidden, true)
+	case astBinary:
+		addHiddenAggregates(selectNode, expr.left)
+		addHiddenAggregates(selectNode, expr.right)
+	case astUnary:
+		addHiddenAggregates(selectNode, expr.left)
+	}
+}
+
+// parseOrderByTerms parses `expr [ASC|DESC] [NULLS FIRST|LAST], ...`.
+func parseOrderByTerms(tokens []*lexToken, tokenIndex *int) []orderByTerm {
+	terms := make([]orderByTerm, 0)
+	for {
+		term := orderByTerm{expr: parseExpression(tokens, tokenIndex)}
+		if checkType(tokens[*tokenIndex], tokenAsc) {
+			(*tokenIndex)++ // Move past ASC
+		} else if checkType(tokens[*tokenIndex], tokenDesc) {
+			term.descending = true
+			(*tokenIndex)++ // Move past DESC
+		}
+
+		// NULLs sort as the largest value unless told otherwise.
+		term.nullsFirst = term.descending
+		if checkType(tokens[*tokenIndex], tokenNulls) {
+			(*tokenIndex)++ // Move past NULLS
+			if checkType(tokens[*tokenIndex], tokenFirst) {
+				term.nullsFirst = true
+			} else {
+				panicIfWrongType(tokens[*tokenIndex], tokenLast, "after NULLS")
+				term.nullsFirst = false
+			}
+			(*tokenIndex)++ // Move past FIRST/LAST
+		}
+		terms = append(terms, term)
+
+		if !checkType(tokens[*tokenIndex], tokenComma) {
+			return terms
+		}
+		(*tokenIndex)++ // Move past comma
+	}
+}
+
+func parseInsertCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenInsert, "")
 	(*tokenIndex)++ // INSERT
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_INTO)
+	panicIfWrongType(tokens[*tokenIndex], tokenInto, "after INSERT")
 	(*tokenIndex)++ // INTO
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_IDENTIFIER)
-	newInsertNode := ASTNode{Type: AST_INSERT}
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after INSERT INTO")
+	newInsertNode := astNode{Type: astInsert}
 	newInsertNode.tableName = tokens[*tokenIndex].value
 	(*tokenIndex)++ // Table name
 
 	// Optional column list
-	if checkType(tokens[*tokenIndex], TOKEN_LPAREN) {
+	if checkType(tokens[*tokenIndex], tokenLParen) {
 		(*tokenIndex)++ // LPAREN
 
-		for !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-			panicIfWrongType(tokens[*tokenIndex], TOKEN_IDENTIFIER)
+		for !checkType(tokens[*tokenIndex], tokenRParen) {
+			panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "in INSERT column list")
 			columnName := tokens[*tokenIndex].value
 			newInsertNode.columnNames = append(newInsertNode.columnNames, columnName)
 			(*tokenIndex)++
 
-			if !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-				panicIfWrongType(tokens[*tokenIndex], TOKEN_COMMA)
+			if !checkType(tokens[*tokenIndex], tokenRParen) {
+				panicIfWrongType(tokens[*tokenIndex], tokenComma, "between INSERT columns")
 				(*tokenIndex)++
 			}
 		}
 		(*tokenIndex)++ // RPAREN
 	}
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_VALUES)
+	panicIfWrongType(tokens[*tokenIndex], tokenValues, "after INSERT table")
 	(*tokenIndex)++ // VALUES
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_LPAREN)
+	panicIfWrongType(tokens[*tokenIndex], tokenLParen, "after VALUES")
 	(*tokenIndex)++ // LPAREN
 
 	// Values
-	for !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-		if checkType(tokens[*tokenIndex], TOKEN_SINGLE_QUOTE) {
+	for !checkType(tokens[*tokenIndex], tokenRParen) {
+		if checkType(tokens[*tokenIndex], tokenSingleQuote) {
 			(*tokenIndex)++ // Opening quote
-			panicIfWrongType(tokens[*tokenIndex], TOKEN_IDENTIFIER)
+			panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after opening quote")
 			val := tokens[*tokenIndex].value
 			newInsertNode.columnValues = append(newInsertNode.columnValues, val)
 			(*tokenIndex)++
-			panicIfWrongType(tokens[*tokenIndex], TOKEN_SINGLE_QUOTE)
+			panicIfWrongType(tokens[*tokenIndex], tokenSingleQuote, "to close quoted value")
 			(*tokenIndex)++ // Closing quote
+		} else if checkType(tokens[*tokenIndex], tokenNull) {
+			// unlike leaving the column out, NULL doesn't pick up DEFAULT
+			newInsertNode.columnValues = append(newInsertNode.columnValues, nil)
+			(*tokenIndex)++ // Move past NULL
 		} else {
+			if isPunctuation(tokens[*tokenIndex]) {
+				parseErrorAt(tokens[*tokenIndex], "expected a value, got %s", describeToken(tokens[*tokenIndex]))
+			}
 			val := tokens[*tokenIndex].value
 			newInsertNode.columnValues = append(newInsertNode.columnValues, val)
 			(*tokenIndex)++
 		}
 
-		if !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-			panicIfWrongType(tokens[*tokenIndex], TOKEN_COMMA)
+		if !checkType(tokens[*tokenIndex], tokenRParen) {
+			panicIfWrongType(tokens[*tokenIndex], tokenComma, "between values")
 			(*tokenIndex)++
 		}
 	}
 
 	(*tokenIndex)++ // RPAREN
-	if checkType(tokens[*tokenIndex], TOKEN_SEMICOLON) {
-		(*tokenIndex)++
-	}
+	expectStatementEnd(tokens, tokenIndex, "INSERT")
 
 	return &newInsertNode
 }
 
-func parseCreateCommand(tokens []*Token, tokenIndex *int) *ASTNode {
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_CREATE)
+func parseUpdateCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenUpdate, "")
+	(*tokenIndex)++ // UPDATE
+
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after UPDATE")
+	updateNode := astNode{Type: astUpdate}
+	updateNode.tableName = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Table name
+
+	panicIfWrongType(tokens[*tokenIndex], tokenSet, "after UPDATE table")
+	(*tokenIndex)++ // SET
+
+	for {
+		panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "in SET list")
+		updateNode.columnNames = append(updateNode.columnNames, tokens[*tokenIndex].value)
+		(*tokenIndex)++ // Column name
+		panicIfWrongType(tokens[*tokenIndex], tokenEquals, "after SET column")
+		(*tokenIndex)++ // =
+		updateNode.setValues = append(updateNode.setValues, parseExpression(tokens, tokenIndex))
+
+		if !checkType(tokens[*tokenIndex], tokenComma) {
+			break
+		}
+		(*tokenIndex)++ // Comma
+	}
+
+	if checkType(tokens[*tokenIndex], tokenWhere) {
+		(*tokenIndex)++ // WHERE
+		updateNode.whereClause = parseExpression(tokens, tokenIndex)
+	}
+
+	expectStatementEnd(tokens, tokenIndex, "UPDATE")
+
+	return &updateNode
+}
+
+func parseDeleteCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenDelete, "")
+	(*tokenIndex)++ // DELETE
+	panicIfWrongType(tokens[*tokenIndex], tokenFrom, "after DELETE")
+	(*tokenIndex)++ // FROM
+
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after DELETE FROM")
+	deleteNode := astNode{Type: astDelete}
+	deleteNode.tableName = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Table name
+
+	if checkType(tokens[*tokenIndex], tokenWhere) {
+		(*tokenIndex)++ // WHERE
+		deleteNode.whereClause = parseExpression(tokens, tokenIndex)
+	}
+
+	expectStatementEnd(tokens, tokenIndex, "DELETE")
+
+	return &deleteNode
+}
+
+func parseTruncateCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenTruncate, "")
+	(*tokenIndex)++ // TRUNCATE
+	if checkType(tokens[*tokenIndex], tokenTable) {
+		(*tokenIndex)++ // TABLE is optional
+	}
+
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after TRUNCATE")
+	truncateNode := astNode{Type: astTruncate}
+	truncateNode.tableName = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Table name
+
+	expectStatementEnd(tokens, tokenIndex, "TRUNCATE")
+
+	return &truncateNode
+}
+
+// parseShowCommand parses SHOW BUDGET. BUDGET is read as a plain identifier
+// so that it stays free for column names.
+func parseShowCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenShow, "")
+	(*tokenIndex)++ // SHOW
+
+	token := tokens[*tokenIndex]
+	if !checkType(token, tokenIdentifier) || !strings.EqualFold(token.value, "BUDGET") {
+		parseErrorAt(token, "expected BUDGET after SHOW, got %s", describeToken(token))
+	}
+	(*tokenIndex)++ // BUDGET
+
+	expectStatementEnd(tokens, tokenIndex, "SHOW BUDGET")
+
+	return &astNode{Type: astShowBudget}
+}
+
+// parseColumnDefinition parses `name TYPE [constraints...]` as used by CREATE
+// TABLE and ALTER TABLE ... ADD COLUMN.
+func parseColumnDefinition(tokens []*lexToken, tokenIndex *int) *astNode {
+	newColumn := astNode{Type: astColumn}
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "for column name")
+	newColumn.name = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Move past column name token
+
+	// Todo: Panic if type is not INT/VARCHAR/...
+	if checkType(tokens[*tokenIndex], tokenVarchar) {
+		newColumn._type = "VARCHAR"
+		(*tokenIndex)++ // Move past VARCHAR token
+		panicIfWrongType(tokens[*tokenIndex], tokenLParen, "after VARCHAR")
+		(*tokenIndex)++ // Move past LPAREN token
+		panicIfWrongType(tokens[*tokenIndex], tokenIntLiteral, "for VARCHAR length")
+		newColumn.varCharLimit, _ = strconv.Atoi(tokens[*tokenIndex].value)
+		(*tokenIndex)++ // Move past INT_LITERAL token
+		panicIfWrongType(tokens[*tokenIndex], tokenRParen, "after VARCHAR length")
+		(*tokenIndex)++ // Move past RPAREN token
+	} else {
+		newColumn._type = strings.ToUpper(tokens[*tokenIndex].value)
+		(*tokenIndex)++ // Move past type token
+	}
+	newColumn.constraints = make([]string, 0)
+
+	// Constraints are kept as normalized strings ("NOT NULL", "DEFAULT 5",
+	// "CHECK (age >= 0)", "BOUNDS(0, 120)", ...), which is also how
+	// dbColumn.Conditions stores them.
+	for !checkType(tokens[*tokenIndex], tokenComma) && !checkType(tokens[*tokenIndex], tokenRParen) &&
+		!checkType(tokens[*tokenIndex], tokenSemicolon) && !checkType(tokens[*tokenIndex], tokenEOF) {
+		switch tokens[*tokenIndex]._type {
+		case tokenPrimary:
+			(*tokenIndex)++ // Move past PRIMARY
+			panicIfWrongType(tokens[*tokenIndex], tokenKey, "after PRIMARY")
+			(*tokenIndex)++ // Move past KEY
+			newColumn.constraints = append(newColumn.constraints, "PRIMARY KEY")
+		case tokenNot:
+			(*tokenIndex)++ // Move past NOT
+			panicIfWrongType(tokens[*tokenIndex], tokenNull, "after NOT")
+			(*tokenIndex)++ // Move past NULL
+			newColumn.constraints = append(newColumn.constraints, "NOT NULL")
+		case tokenNull:
+			(*tokenIndex)++ // Explicitly nullable, which is the default anyway
+		case tokenUnique:
+			(*tokenIndex)++ // Move past UNIQUE
+			newColumn.constraints = append(newColumn.constraints, "UNIQUE")
+		case tokenAutoIncrement:
+			(*tokenIndex)++ // Move past AUTO_INCREMENT
+			newColumn.constraints = append(newColumn.constraints, "AUTO_INCREMENT")
+		case tokenDefault:
+			(*tokenIndex)++ // Move past DEFAULT
+			if isPunctuation(tokens[*tokenIndex]) {
+				parseErrorAt(tokens[*tokenIndex], "expected a value after DEFAULT, got %s", describeToken(tokens[*tokenIndex]))
+			}
+			if !checkType(tokens[*tokenIndex], tokenNull) {
+				newColumn.constraints = append(newColumn.constraints, "DEFAULT "+tokens[*tokenIndex].value)
+			}
+			(*tokenIndex)++ // Move past the default value
+		case tokenCheck:
+			(*tokenIndex)++ // Move past CHECK
+			panicIfWrongType(tokens[*tokenIndex], tokenLParen, "after CHECK")
+			check := parsePrimaryExpression(tokens, tokenIndex) // the parenthesised condition
+			newColumn.constraints = append(newColumn.constraints, "CHECK "+formatExpression(check))
+		case tokenBounds:
+			boundsToken := tokens[*tokenIndex]
+			(*tokenIndex)++ // Move past BOUNDS
+			panicIfWrongType(tokens[*tokenIndex], tokenLParen, "after BOUNDS")
+			(*tokenIndex)++ // Move past LPAREN
+			lo := parseBound(tokens, tokenIndex)
+			panicIfWrongType(tokens[*tokenIndex], tokenComma, "between BOUNDS")
+			(*tokenIndex)++ // Move past COMMA
+			hi := parseBound(tokens, tokenIndex)
+			panicIfWrongType(tokens[*tokenIndex], tokenRParen, "after BOUNDS")
+			(*tokenIndex)++ // Move past RPAREN
+			if lo > hi {
+				parseErrorAt(boundsToken, "BOUNDS on column %s: lower bound %v is above upper bound %v", newColumn.name, lo, hi)
+			}
+			newColumn.constraints = append(newColumn.constraints, formatBounds(lo, hi))
+		case tokenReferences:
+			newColumn.foreignKeys = append(newColumn.foreignKeys, parseReferences(tokens, tokenIndex, []string{newColumn.name}))
+		default:
+			parseErrorAt(tokens[*tokenIndex], "unknown constraint %s on column %s", tokens[*tokenIndex].value, newColumn.name)
+		}
+	}
+	return &newColumn
+}
+
+// parseBound parses one number of BOUNDS(lo, hi).
+func parseBound(tokens []*lexToken, tokenIndex *int) float64 {
+	token := tokens[*tokenIndex]
+	if !checkType(token, tokenIntLiteral) && !checkType(token, tokenFloatLiteral) {
+		parseErrorAt(token, "expected a number in BOUNDS, got %s", describeToken(token))
+	}
+	value, err := strconv.ParseFloat(token.value, 64)
+	if err != nil {
+		parseErrorAt(token, "invalid number %s", token.value)
+	}
+	(*tokenIndex)++ // Move past the number
+	return value
+}
+
+// parseIdentifierList parses a parenthesised, comma separated list of names.
+func parseIdentifierList(tokens []*lexToken, tokenIndex *int) []string {
+	panicIfWrongType(tokens[*tokenIndex], tokenLParen, "to open column list")
+	(*tokenIndex)++ // Move past LPAREN
+	names := make([]string, 0)
+	for {
+		panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "in column list")
+		names = append(names, tokens[*tokenIndex].value)
+		(*tokenIndex)++ // Move past name
+		if !checkType(tokens[*tokenIndex], tokenComma) {
+			break
+		}
+		(*tokenIndex)++ // Move past COMMA
+	}
+	panicIfWrongType(tokens[*tokenIndex], tokenRParen, "to close column list")
+	(*tokenIndex)++ // Move past RPAREN
+	return names
+}
+
+// parseReferences parses `REFERENCES table(columns) [ON DELETE action]` for
+// the given referencing columns.
+func parseReferences(tokens []*lexToken, tokenIndex *int, columns []string) foreignKey {
+	panicIfWrongType(tokens[*tokenIndex], tokenReferences, "")
+	(*tokenIndex)++ // Move past REFERENCES
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after REFERENCES")
+	fk := foreignKey{Columns: columns, RefTable: tokens[*tokenIndex].value, OnDelete: "RESTRICT"}
+	(*tokenIndex)++ // Move past table name
+	fk.RefColumns = parseIdentifierList(tokens, tokenIndex)
+
+	if checkType(tokens[*tokenIndex], tokenOn) {
+		(*tokenIndex)++ // Move past ON
+		panicIfWrongType(tokens[*tokenIndex], tokenDelete, "after ON")
+		(*tokenIndex)++ // Move past DELETE
+		switch tokens[*tokenIndex]._type {
+		case tokenRestrict:
+			fk.OnDelete = "RESTRICT"
+		case tokenCascade:
+			fk.OnDelete = "CASCADE"
+		case tokenSet:
+			(*tokenIndex)++ // Move past SET
+			panicIfWrongType(tokens[*tokenIndex], tokenNull, "after ON DELETE SET")
+			fk.OnDelete = "SET NULL"
+		default:
+			parseErrorAt(tokens[*tokenIndex], "expected RESTRICT, CASCADE or SET NULL after ON DELETE, got %s", describeToken(tokens[*tokenIndex]))
+		}
+		(*tokenIndex)++ // Move past the action
+	}
+	return fk
+}
+
+// parseDropCommand parses DROP TABLE [IF EXISTS] name.
+func parseDropCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenDrop, "")
+	(*tokenIndex)++ // DROP
+	panicIfWrongType(tokens[*tokenIndex], tokenTable, "after DROP")
+	(*tokenIndex)++ // TABLE
+
+	dropNode := astNode{Type: astDrop}
+	if checkType(tokens[*tokenIndex], tokenIf) {
+		(*tokenIndex)++ // IF
+		panicIfWrongType(tokens[*tokenIndex], tokenExists, "after IF")
+		(*tokenIndex)++ // EXISTS
+		dropNode.ifExists = true
+	}
+
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after DROP TABLE")
+	dropNode.tableName = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Table name
+
+	expectStatementEnd(tokens, tokenIndex, "DROP TABLE")
+	return &dropNode
+}
+
+// parseAlterCommand parses ALTER TABLE name followed by one of
+// ADD [COLUMN] definition, DROP [COLUMN] column,
+// RENAME [COLUMN] column TO new_name or RENAME TO new_table_name.
+func parseAlterCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenAlter, "")
+	(*tokenIndex)++ // ALTER
+	panicIfWrongType(tokens[*tokenIndex], tokenTable, "after ALTER")
+	(*tokenIndex)++ // TABLE
+
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after ALTER TABLE")
+	alterNode := astNode{Type: astAlter}
+	alterNode.tableName = tokens[*tokenIndex].value
+	(*tokenIndex)++ // Table name
+
+	switch tokens[*tokenIndex]._type {
+	case tokenAdd:
+		(*tokenIndex)++ // ADD
+		if checkType(tokens[*tokenIndex], tokenColumn) {
+			(*tokenIndex)++ // COLUMN
+		}
+		alterNode.alterAction = "ADD COLUMN"
+		alterNode.columns = []*astNode{parseColumnDefinition(tokens, tokenIndex)}
+	case tokenDrop:
+		(*tokenIndex)++ // DROP
+		if checkType(tokens[*tokenIndex], tokenColumn) {
+			(*tokenIndex)++ // COLUMN
+		}
+		alterNode.alterAction = "DROP COLUMN"
+		panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after DROP COLUMN")
+		alterNode.name = tokens[*tokenIndex].value
+		(*tokenIndex)++ // Column name
+	case tokenRename:
+		(*tokenIndex)++ // RENAME
+		if checkType(tokens[*tokenIndex], tokenTo) {
+			(*tokenIndex)++ // TO
+			alterNode.alterAction = "RENAME TO"
+		} else {
+			if checkType(tokens[*tokenIndex], tokenColumn) {
+				(*tokenIndex)++ // COLUMN
+			}
+			alterNode.alterAction = "RENAME COLUMN"
+			panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after RENAME COLUMN")
+			alterNode.name = tokens[*tokenIndex].value
+			(*tokenIndex)++ // Column name
+			panicIfWrongType(tokens[*tokenIndex], tokenTo, "after column to rename")
+			(*tokenIndex)++ // TO
+		}
+		panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "for new name")
+		alterNode.newName = tokens[*tokenIndex].value
+		(*tokenIndex)++ // New name
+	default:
+		parseErrorAt(tokens[*tokenIndex], "expected ADD, DROP or RENAME after ALTER TABLE %s, got %s", alterNode.tableName, describeToken(tokens[*tokenIndex]))
+	}
+
+	expectStatementEnd(tokens, tokenIndex, "ALTER TABLE")
+	return &alterNode
+}
+
+// parseTransactionCommand parses BEGIN [TRANSACTION], COMMIT [TRANSACTION],
+// ROLLBACK [TRANSACTION], SAVEPOINT name, ROLLBACK TO [SAVEPOINT] name and
+// RELEASE [SAVEPOINT] name.
+func parseTransactionCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	transactionNode := astNode{Type: astTransaction}
+	parseSavepointName := func() {
+		if checkType(tokens[*tokenIndex], tokenSavepoint) {
+			(*tokenIndex)++ // Move past SAVEPOINT
+		}
+		panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "for savepoint name")
+		transactionNode.name = tokens[*tokenIndex].value
+		(*tokenIndex)++ // Move past savepoint name
+	}
+
+	switch tokens[*tokenIndex]._type {
+	case tokenBegin:
+		transactionNode.transactionAction = "BEGIN"
+		(*tokenIndex)++ // Move past BEGIN
+	case tokenCommit:
+		transactionNode.transactionAction = "COMMIT"
+		(*tokenIndex)++ // Move past COMMIT
+	case tokenRollback:
+		transactionNode.transactionAction = "ROLLBACK"
+		(*tokenIndex)++ // Move past ROLLBACK
+		if checkType(tokens[*tokenIndex], tokenTo) {
+			(*tokenIndex)++ // Move past TO
+			transactionNode.transactionAction = "ROLLBACK TO"
+			parseSavepointName()
+		}
+	case tokenSavepoint:
+		transactionNode.transactionAction = "SAVEPOINT"
+		parseSavepointName()
+	case tokenRelease:
+		transactionNode.transactionAction = "RELEASE"
+		(*tokenIndex)++ // Move past RELEASE
+		parseSavepointName()
+	default:
+		parseErrorAt(tokens[*tokenIndex], "expected BEGIN, COMMIT, ROLLBACK, SAVEPOINT or RELEASE, got %s", describeToken(tokens[*tokenIndex]))
+	}
+	if checkType(tokens[*tokenIndex], tokenTransaction) && transactionNode.name == "" {
+		(*tokenIndex)++ // Move past TRANSACTION
+	}
+
+	expectStatementEnd(tokens, tokenIndex, transactionNode.transactionAction)
+	return &transactionNode
+}
+
+func parseCreateCommand(tokens []*lexToken, tokenIndex *int) *astNode {
+	panicIfWrongType(tokens[*tokenIndex], tokenCreate, "")
 	(*tokenIndex)++ // Move past CREATE token
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_TABLE)
+	panicIfWrongType(tokens[*tokenIndex], tokenTable, "after CREATE")
 	(*tokenIndex)++ // Move past TABLE token
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_IDENTIFIER)
+	panicIfWrongType(tokens[*tokenIndex], tokenIdentifier, "after CREATE TABLE")
 	tableName := tokens[*tokenIndex].value
 	(*tokenIndex)++ // Move past table name token
 
-	newColumns := make([]*ASTNode, 0)
+	newColumns := make([]*astNode, 0)
+	foreignKeys := make([]foreignKey, 0)
 
-	if checkType(tokens[*tokenIndex], TOKEN_SEMICOLON) {
-		(*tokenIndex)++ // Ingest semicolon (empty column list)
-	} else if checkType(tokens[*tokenIndex], TOKEN_LPAREN) {
+	if checkType(tokens[*tokenIndex], tokenSemicolon) || checkType(tokens[*tokenIndex], tokenEOF) {
+		// empty column list
+	} else if checkType(tokens[*tokenIndex], tokenLParen) {
 		(*tokenIndex)++ // Move past LPAREN token
-		for !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-			newColumn := ASTNode{Type: AST_COLUMN}
-			panicIfWrongType(tokens[*tokenIndex], TOKEN_IDENTIFIER)
-			newColumn.name = tokens[*tokenIndex].value
-			(*tokenIndex)++ // Move past column name token
-
-			// Todo: Panic if type is not INT/VARCHAR/...
-			if checkType(tokens[*tokenIndex], TOKEN_VARCHAR) {
-				newColumn._type = "VARCHAR"
-				(*tokenIndex)++ // Move past VARCHAR token
-				panicIfWrongType(tokens[*tokenIndex], TOKEN_LPAREN)
-				(*tokenIndex)++ // Move past LPAREN token
-				panicIfWrongType(tokens[*tokenIndex], TOKEN_INT_LITERAL)
-				newColumn.varCharLimit, _ = strconv.Atoi(tokens[*tokenIndex].value)
-				(*tokenIndex)++ // Move past INT_LITERAL token
-				panicIfWrongType(tokens[*tokenIndex], TOKEN_RPAREN)
-				(*tokenIndex)++ // Move past RPAREN token
+		for !checkType(tokens[*tokenIndex], tokenRParen) {
+			if checkType(tokens[*tokenIndex], tokenForeign) {
+				(*tokenIndex)++ // Move past FOREIGN
+				panicIfWrongType(tokens[*tokenIndex], tokenKey, "after FOREIGN")
+				(*tokenIndex)++ // Move past KEY
+				columns := parseIdentifierList(tokens, tokenIndex)
+				foreignKeys = append(foreignKeys, parseReferences(tokens, tokenIndex, columns))
 			} else {
-				newColumn._type = tokens[*tokenIndex].value
-				(*tokenIndex)++ // Move past type token
+				newColumn := parseColumnDefinition(tokens, tokenIndex)
+				newColumns = append(newColumns, newColumn)
+				foreignKeys = append(foreignKeys, newColumn.foreignKeys...)
 			}
-			newColumn.constraints = make([]string, 0)
-
-			for !checkType(tokens[*tokenIndex], TOKEN_COMMA) && !checkType(tokens[*tokenIndex], TOKEN_RPAREN) {
-				if checkType(tokens[*tokenIndex], TOKEN_PRIMARY) {
-					(*tokenIndex)++ // Move past PRIMARY
-					panicIfWrongType(tokens[*tokenIndex], TOKEN_KEY)
-					(*tokenIndex)++ // Move past KEY
-					newColumn.constraints = append(newColumn.constraints, "PRIMARY KEY")
-				} else {
-					newColumn.constraints = append(newColumn.constraints, tokens[*tokenIndex].value)
-					(*tokenIndex)++
-				}
-			}
-			if checkType(tokens[*tokenIndex], TOKEN_COMMA) {
+			if checkType(tokens[*tokenIndex], tokenComma) {
 				(*tokenIndex)++ // Ingest comma
 			}
-			newColumns = append(newColumns, &newColumn)
 		}
 		(*tokenIndex)++ // Move past RPAREN token
 	} else {
-		panic("Invalid type")
+		parseErrorAt(tokens[*tokenIndex], "expected '(' or ';' after CREATE TABLE %s, got %s", tableName, describeToken(tokens[*tokenIndex]))
 	}
 
-	panicIfWrongType(tokens[*tokenIndex], TOKEN_SEMICOLON)
-	(*tokenIndex)++ // Move past SEMICOLON token
+	expectStatementEnd(tokens, tokenIndex, "CREATE TABLE")
 
-	newCreateNode := ASTNode{
-		Type:      AST_CREATE,
-		tableName: tableName,
-		columns:   newColumns,
+	newCreateNode := astNode{
+		Type:        astCreate,
+		tableName:   tableName,
+		columns:     newColumns,
+		foreignKeys: foreignKeys,
 	}
 	return &newCreateNode
 }
 
-func parseCommands(tokens []*Token) []*ASTNode {
-	retNodes := make([]*ASTNode, 0)
+// parseCommands parses every statement in tokens. A statement with a syntax
+// error is reported in the returned errors and dropped whole, from its first
+// token to the ';' that ends it, so the statements after it are still parsed.
+func parseCommands(tokens []*lexToken) ([]*astNode, []*ParseError) {
+	retNodes := make([]*astNode, 0)
+	parseErrors := make([]*ParseError, 0)
 	tokenIndex := 0
-	for tokenIndex < len(tokens) && tokens[tokenIndex]._type != TOKEN_EOF {
-		if tokens[tokenIndex]._type == TOKEN_CREATE {
-			retNodes = append(retNodes, parseCreateCommand(tokens, &tokenIndex))
-		} else if tokens[tokenIndex]._type == TOKEN_INSERT {
-			retNodes = append(retNodes, parseInsertCommand(tokens, &tokenIndex))
-		} else if tokens[tokenIndex]._type == TOKEN_SELECT {
-			retNodes = append(retNodes, parseSelectCommand(tokens, &tokenIndex))
-		} else {
-			// Skip unhandled tokens.
-			tokenIndex++
+	for tokenIndex < len(tokens) && tokens[tokenIndex]._type != tokenEOF {
+		startIndex := tokenIndex
+		node, err := parseCommand(tokens, &tokenIndex)
+		if err != nil {
+			parseErrors = append(parseErrors, err)
+			// from the start: the parser may have run past the ';' looking
+			// for something that never came
+			tokenIndex = skipStatement(tokens, startIndex)
+			continue
+		}
+		if node != nil {
+			node.sourceStart = tokens[startIndex].start
+			node.sourceEnd = tokens[tokenIndex-1].end
+			retNodes = append(retNodes, node)
 		}
 	}
-	return retNodes
+	return retNodes, parseErrors
+}
+
+// parseCommand parses the statement at tokenIndex, turning a panic on the
+// way into a ParseError. It returns a nil node for a stray ';'.
+func parseCommand(tokens []*lexToken, tokenIndex *int) (node *astNode, err *ParseError) {
+	startToken := tokens[*tokenIndex]
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if parseErr, ok := r.(*ParseError); ok {
+			err = parseErr
+			return
+		}
+		// Anything else means the parser read past the last token, or hit a
+		// case it doesn't check for; report it at the statement.
+		eof := tokens[len(tokens)-1]
+		if *tokenIndex >= len(tokens)-1 {
+			err = &ParseError{Line: eof.line, Col: eof.col, Message: "unexpected end of input"}
+			return
+		}
+		err = &ParseError{Line: startToken.line, Col: startToken.col, Message: fmt.Sprint(r)}
+	}()
+
+	switch {
+	case checkType(startToken, tokenCreate):
+		return parseCreateCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenInsert):
+		return parseInsertCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenSelect):
+		return parseSelectCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenUpdate):
+		return parseUpdateCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenDelete):
+		return parseDeleteCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenTruncate):
+		return parseTruncateCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenShow):
+		return parseShowCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenDrop):
+		return parseDropCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenAlter):
+		return parseAlterCommand(tokens, tokenIndex), nil
+	case isTransactionStart(startToken):
+		return parseTransactionCommand(tokens, tokenIndex), nil
+	case checkType(startToken, tokenSemicolon):
+		(*tokenIndex)++ // Skip empty statements.
+		return nil, nil
+	default:
+		parseErrorAt(startToken, "expected a statement, got %s", describeToken(startToken))
+		return nil, nil
+	}
+}
+
+// skipStatement returns the index just past the ';' that ends the statement
+// containing tokenIndex, or of the EOF token if there is none.
+func skipStatement(tokens []*lexToken, tokenIndex int) int {
+	if tokenIndex >= len(tokens) {
+		return len(tokens) - 1
+	}
+	for !checkType(tokens[tokenIndex], tokenEOF) {
+		if checkType(tokens[tokenIndex], tokenSemicolon) {
+			return tokenIndex + 1
+		}
+		tokenIndex++
+	}
+	return tokenIndex
+}
+
+func isTransactionStart(token *lexToken) bool {
+	switch token._type {
+	case tokenBegin, tokenCommit, tokenRollback, tokenSavepoint, tokenRelease:
+		return true
+	}
+	return false
+}
+
+// statementSource returns the SQL text a statement node was parsed from.
+func statementSource(input string, node *astNode) string {
+	return input[node.sourceStart:node.sourceEnd]
+}
+
+// formatExpression turns an expression back into SQL that parseExpression
+// accepts. Binary expressions are fully parenthesised so precedence survives.
+func formatExpression(expr *astNode) string {
+	switch expr.Type {
+	case astIntLiteral:
+		return strconv.FormatInt(expr.intVal, 10)
+	case astFloatLiteral:
+		return strconv.FormatFloat(expr.floatVal, 'f', -1, 64)
+	case astVarcharLiteral:
+		return "'" + strings.ReplaceAll(expr.strVal, "'", "''") + "'"
+	case astBooleanLiteral:
+		if expr.boolValue {
+			return "TRUE"
+		}
+		return "FALSE"
+	case astColumnName:
+		// table.column was joined into one name by parseColumnReference
+		if table, column, ok := strings.Cut(expr.columnName, "."); ok {
+			return quoteIdentifier(table) + "." + quoteIdentifier(column)
+		}
+		if expr.columnName == "*" {
+			return "*"
+		}
+		return quoteIdentifier(expr.columnName)
+	case astFunction:
+		args := make([]string, len(expr.functionArguements))
+		for i, arg := range expr.functionArguements {
+			args[i] = formatExpression(arg)
+		}
+		return strings.ToUpper(expr.functionName) + "(" + strings.Join(args, ", ") + ")"
+	case astUnary:
+		if expr.operator == "NOT" {
+			return "NOT " + formatExpression(expr.left)
+		}
+		return "(" + formatExpression(expr.left) + " " + expr.operator + ")"
+	case astBinary:
+		return "(" + formatExpression(expr.left) + " " + expr.operator + " " + formatExpression(expr.right) + ")"
+	default:
+		panic(fmt.Sprintf("Cannot format AST node type %d as SQL", expr.Type))
+	}
 }
 
 // --- Print AST function ---
-func printAST(node *ASTNode, indent int) {
+func printAST(w io.Writer, node *astNode, indent int) {
 	indentStr := strings.Repeat("  ", indent)
 	switch node.Type {
-	case AST_CREATE:
-		fmt.Printf("%sCREATE TABLE %s\n", indentStr, node.tableName)
-		fmt.Printf("%sColumns:\n", indentStr)
+	case astCreate:
+		fmt.Fprintf(w, "%sCREATE TABLE %s\n", indentStr, node.tableName)
+		fmt.Fprintf(w, "%sColumns:\n", indentStr)
 		for _, col := range node.columns {
-			fmt.Printf("%s- Column: %s, Type: %s", indentStr+"  ", col.name, col._type)
+			fmt.Fprintf(w, "%s- Column: %s, Type: %s", indentStr+"  ", col.name, col._type)
 			if col._type == "VARCHAR" {
-				fmt.Printf("(%d)", col.varCharLimit)
+				fmt.Fprintf(w, "(%d)", col.varCharLimit)
 			}
 			if len(col.constraints) > 0 {
-				fmt.Printf(", Constraints: %v", col.constraints)
+				fmt.Fprintf(w, ", Constraints: %v", col.constraints)
 			}
-			fmt.Println()
+			fmt.Fprintln(w)
+		}
+		for _, fk := range node.foreignKeys {
+			fmt.Fprintf(w, "%s- %s\n", indentStr+"  ", fk)
 		}
-	case AST_INSERT:
-		fmt.Printf("%sINSERT INTO %s\n", indentStr, node.tableName)
+	case astInsert:
+		fmt.Fprintf(w, "%sINSERT INTO %s\n", indentStr, node.tableName)
 		if len(node.columnNames) > 0 {
-			fmt.Printf("%sColumns: %s\n", indentStr+"  ", strings.Join(node.columnNames, ", "))
+			fmt.Fprintf(w, "%sColumns: %s\n", indentStr+"  ", strings.Join(node.columnNames, ", "))
 		}
 		if len(node.columnValues) > 0 {
-			fmt.Printf("%sValues: %s\n", indentStr+"  ", strings.Join(node.columnValues, ", "))
+			values := make([]string, len(node.columnValues))
+			for i, value := range node.columnValues {
+				if value == nil {
+					values[i] = "NULL"
+				} else {
+					values[i] = value.(string)
+				}
+			}
+			fmt.Fprintf(w, "%sValues: %s\n", indentStr+"  ", strings.Join(values, ", "))
+		}
+	case astUpdate:
+		fmt.Fprintf(w, "%sUPDATE %s\n", indentStr, node.tableName)
+		for i, name := range node.columnNames {
+			fmt.Fprintf(w, "%sSET %s =\n", indentStr+"  ", name)
+			printAST(w, node.setValues[i], indent+2)
+		}
+		if node.whereClause != nil {
+			fmt.Fprintf(w, "%sWHERE:\n", indentStr+"  ")
+			printAST(w, node.whereClause, indent+2)
+		}
+	case astDelete:
+		fmt.Fprintf(w, "%sDELETE FROM %s\n", indentStr, node.tableName)
+		if node.whereClause != nil {
+			fmt.Fprintf(w, "%sWHERE:\n", indentStr+"  ")
+			printAST(w, node.whereClause, indent+2)
+		}
+	case astTruncate:
+		fmt.Fprintf(w, "%sTRUNCATE TABLE %s\n", indentStr, node.tableName)
+	case astShowBudget:
+		fmt.Fprintf(w, "%sSHOW BUDGET\n", indentStr)
+	case astDrop:
+		fmt.Fprintf(w, "%sDROP TABLE %s", indentStr, node.tableName)
+		if node.ifExists {
+			fmt.Fprintf(w, " (IF EXISTS)")
 		}
-	case AST_SELECT:
-		fmt.Printf("%sSELECT statement\n", indentStr)
+		fmt.Fprintln(w)
+	case astTransaction:
+		fmt.Fprintf(w, "%s%s", indentStr, node.transactionAction)
+		if node.name != "" {
+			fmt.Fprintf(w, " %s", node.name)
+		}
+		fmt.Fprintln(w)
+	case astAlter:
+		fmt.Fprintf(w, "%sALTER TABLE %s %s", indentStr, node.tableName, node.alterAction)
+		switch node.alterAction {
+		case "ADD COLUMN":
+			fmt.Fprintf(w, " %s %s", node.columns[0].name, node.columns[0]._type)
+		case "DROP COLUMN":
+			fmt.Fprintf(w, " %s", node.name)
+		case "RENAME COLUMN":
+			fmt.Fprintf(w, " %s TO %s", node.name, node.newName)
+		case "RENAME TO":
+			fmt.Fprintf(w, " %s", node.newName)
+		}
+		fmt.Fprintln(w)
+	case astSelect:
+		fmt.Fprintf(w, "%sSELECT statement\n", indentStr)
 		if len(node.columns) > 0 {
-			fmt.Printf("%sColumns:\n", indentStr+"  ")
+			fmt.Fprintf(w, "%sColumns:\n", indentStr+"  ")
 			for i, col := range node.columns {
-				fmt.Printf("%s- %s", indentStr+"    ", col.columnName)
+				fmt.Fprintf(w, "%s- %s", indentStr+"    ", col.columnName)
 				if i < len(node.columnNames) && node.columnNames[i] != "" {
-					fmt.Printf(" AS %s", node.columnNames[i])
+					fmt.Fprintf(w, " AS %s", node.columnNames[i])
 				}
-				fmt.Println()
+				fmt.Fprintln(w)
 			}
 			if node.containsGroupBy {
-				fmt.Printf("%sGROUP BY: %s\n", indentStr+"  ", strings.Join(node.groupByColumns, ", "))
+				fmt.Fprintf(w, "%sGROUP BY: %s\n", indentStr+"  ", strings.Join(node.groupByColumns, ", "))
+			}
+		}
+		fmt.Fprintf(w, "%sFROM: %s\n", indentStr+"  ", node.tableName)
+		for _, join := range node.joins {
+			printAST(w, join, indent+1)
+		}
+		if node.whereClause != nil {
+			fmt.Fprintf(w, "%sWHERE:\n", indentStr+"  ")
+			printAST(w, node.whereClause, indent+2)
+		}
+		if node.havingClause != nil {
+			fmt.Fprintf(w, "%sHAVING:\n", indentStr+"  ")
+			printAST(w, node.havingClause, indent+2)
+		}
+		if len(node.orderBy) > 0 {
+			fmt.Fprintf(w, "%sORDER BY:\n", indentStr+"  ")
+			for _, term := range node.orderBy {
+				direction := "ASC"
+				if term.descending {
+					direction = "DESC"
+				}
+				nulls := "LAST"
+				if term.nullsFirst {
+					nulls = "FIRST"
+				}
+				fmt.Fprintf(w, "%s%s NULLS %s\n", indentStr+"    ", direction, nulls)
+				printAST(w, term.expr, indent+3)
 			}
 		}
-		fmt.Printf("%sFROM: %s\n", indentStr+"  ", node.tableName)
-	case AST_FUNCTION:
-		fmt.Printf("%sFUNCTION: %s\n", indentStr, node.functionName)
-		fmt.Printf("%sArguments:\n", indentStr+"  ")
+		if node.containsLimit {
+			fmt.Fprintf(w, "%sLIMIT: %d\n", indentStr+"  ", node.limit)
+		}
+		if node.containsOffset {
+			fmt.Fprintf(w, "%sOFFSET: %d\n", indentStr+"  ", node.offset)
+		}
+	case astFunction:
+		fmt.Fprintf(w, "%sFUNCTION: %s\n", indentStr, node.functionName)
+		fmt.Fprintf(w, "%sArguments:\n", indentStr+"  ")
 		for _, arg := range node.functionArguements {
-			printAST(arg, indent+2)
-		}
-	case AST_BINARY:
-		fmt.Printf("%sBINARY EXPRESSION:\n", indentStr)
-		fmt.Printf("%sLeft:\n", indentStr+"  ")
-		printAST(node.left, indent+2)
-		fmt.Printf("%sOperator: %s\n", indentStr+"  ", node.operator)
-		fmt.Printf("%sRight:\n", indentStr+"  ")
-		printAST(node.right, indent+2)
-	case AST_INT_LITERAL:
-		fmt.Printf("%sINT_LITERAL: %d\n", indentStr, node.intVal)
-	case AST_VARCHAR_LITERAL:
-		fmt.Printf("%sVARCHAR_LITERAL: %s\n", indentStr, node.strVal)
-	case AST_FLOAT_LITERAL:
-		fmt.Printf("%sFLOAT_LITERAL: %f\n", indentStr, node.floatVal)
-	case AST_BOOLEAN_LITERAL:
-		fmt.Printf("%sBOOLEAN_LITERAL: %t\n", indentStr, node.boolValue)
-	case AST_COLUMN_NAME:
-		fmt.Printf("%sCOLUMN_NAME: %s\n", indentStr, node.columnName)
+			printAST(w, arg, indent+2)
+		}
+	case astBinary:
+		fmt.Fprintf(w, "%sBINARY EXPRESSION:\n", indentStr)
+		fmt.Fprintf(w, "%sLeft:\n", indentStr+"  ")
+		printAST(w, node.left, indent+2)
+		fmt.Fprintf(w, "%sOperator: %s\n", indentStr+"  ", node.operator)
+		fmt.Fprintf(w, "%sRight:\n", indentStr+"  ")
+		printAST(w, node.right, indent+2)
+	case astJoin:
+		fmt.Fprintf(w, "%s%s JOIN %s", indentStr, node.joinType, node.tableName)
+		if node.tableAlias != "" {
+			fmt.Fprintf(w, " AS %s", node.tableAlias)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%sON:\n", indentStr+"  ")
+		printAST(w, node.onClause, indent+2)
+	case astUnary:
+		fmt.Fprintf(w, "%sUNARY EXPRESSION: %s\n", indentStr, node.operator)
+		printAST(w, node.left, indent+1)
+	case astIntLiteral:
+		fmt.Fprintf(w, "%sINT_LITERAL: %d\n", indentStr, node.intVal)
+	case astVarcharLiteral:
+		fmt.Fprintf(w, "%sVARCHAR_LITERAL: %s\n", indentStr, node.strVal)
+	case astFloatLiteral:
+		fmt.Fprintf(w, "%sFLOAT_LITERAL: %f\n", indentStr, node.floatVal)
+	case astBooleanLiteral:
+		fmt.Fprintf(w, "%sBOOLEAN_LITERAL: %t\n", indentStr, node.boolValue)
+	case astColumnName:
+		fmt.Fprintf(w, "%sCOLUMN_NAME: %s\n", indentStr, node.columnName)
 	default:
-		fmt.Printf("%sUnknown AST Node type: %d\n", indentStr, node.Type)
+		fmt.Fprintf(w, "%sUnknown AST Node type: %d\n", indentStr, node.Type)
 	}
 }
@@ -0,0 +1,79 @@
+package dpsql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRecoversFromErrors(t *testing.T) {
+	tests := []struct {
+		input      string
+		statements []string
+		errors     []string
+	}{
+		{"SELECT a FROM t; SELEC b FROM t; INSERT INTO t (a) VALUES (1);",
+			[]string{"SELECT a FROM t;", "INSERT INTO t (a) VALUES (1);"},
+			[]string{"line 1, col 18: expected a statement, got IDENTIFIER"}},
+		// the bad statement goes up to its ';', not to where the parser gave up
+		{"INSERT INTO t (a) VALUES (1; SELECT a FROM t;",
+			[]string{"SELECT a FROM t;"},
+			[]string{"line 1, col 28: expected ',' between values, got ';'"}},
+		{"SELECT COUNT(x FROM t; DROP TABLE t;",
+			[]string{"DROP TABLE t;"},
+			[]string{"line 1, col 16: expected ')' after aggregate argument, got FROM"}},
+		// a ';' in a string doesn't end the statement
+		{"SELECT a FROM t WHERE a = 'x;' AND ; CREATE TABLE u (x INT);",
+			[]string{"CREATE TABLE u (x INT);"},
+			[]string{"line 1, col 36: expected a column name, got ';'"}},
+		{"UPDATE t SET a = ; UPDATE t SET a = 1; DELETE FROM t WHERE a = 1 2;",
+			[]string{"UPDATE t SET a = 1;"},
+			[]string{"line 1, col 18: expected a column name, got ';'", "line 1, col 66: expected ';' to end DELETE, got INT_LITERAL"}},
+		// a missing ';' between two statements loses both
+		{"CREATE TABLE t (x INT)\nSELECT x FROM t;",
+			nil,
+			[]string{"line 2, col 1: expected ';' to end CREATE TABLE, got SELECT"}},
+		{"SELECT a FROM",
+			nil,
+			[]string{"line 1, col 14: expected IDENTIFIER after FROM, got end of input"}},
+		{"'abc",
+			nil,
+			[]string{"line 1, col 1: expected a statement, got ILLEGAL (Unterminated string)"}},
+		{"SELECT a FROM t WHERE a = 1 /* unterminated",
+			nil,
+			[]string{"line 1, col 29: expected ';' to end SELECT, got ILLEGAL (Unterminated comment)"}},
+		// the last statement may leave out its ';', and empty ones are skipped
+		{";;SELECT a FROM t;; SELECT b FROM t",
+			[]string{"SELECT a FROM t;", "SELECT b FROM t"},
+			nil},
+	}
+	for _, test := range tests {
+		statements, parseErrors := Parse(test.input)
+		var gotStatements, gotErrors []string
+		for _, statement := range statements {
+			gotStatements = append(gotStatements, statement.SQL)
+		}
+		for _, parseErr := range parseErrors {
+			gotErrors = append(gotErrors, parseErr.Error())
+		}
+		if !reflect.DeepEqual(gotStatements, test.statements) {
+			t.Errorf("Parse(%q) statements = %q, want %q", test.input, gotStatements, test.statements)
+		}
+		if !reflect.DeepEqual(gotErrors, test.errors) {
+			t.Errorf("Parse(%q) errors = %q, want %q", test.input, gotErrors, test.errors)
+		}
+	}
+}
+
+func TestExecRunsNothingAfterSyntaxError(t *testing.T) {
+	db, err := Open(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE t (x INT); INSERT INTO t (x) VALUES (1; INSERT INTO t (x) VALUES (2);"); err == nil {
+		t.Fatal("Exec with a syntax error succeeded")
+	}
+	if tables := db.Tables(); len(tables) != 0 {
+		t.Errorf("tables = %v, want none", tables)
+	}
+}
@@ -0,0 +1,154 @@
+package dpsql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// databaseFormatVersion is bumped whenever databaseFile changes shape.
+const databaseFormatVersion = 1
+
+// databaseFile is the on-disk form of the database: every table with its
+// schema, constraints and rows, as of write-ahead log record LastLSN.
+type databaseFile struct {
+	Version int
+	LastLSN uint64
+	Tables  []dbTable
+}
+
+// saveDatabase writes tables to path, noting that it includes every
+// write-ahead log record up to lastLSN. The file is written next to path
+// and renamed over it, so a crash leaves either the old or the new database,
+// never half of one.
+func saveDatabase(path string, tables map[string]dbTable, lastLSN uint64) error {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	file := databaseFile{Version: databaseFormatVersion, LastLSN: lastLSN, Tables: make([]dbTable, 0, len(names))}
+	for _, name := range names {
+		file.Tables = append(file.Tables, tables[name])
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once the rename went through
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadDatabase reads the tables saved at path and the last write-ahead log
+// record they include. It returns an error wrapping os.ErrNotExist if there
+// is no saved database.
+func loadDatabase(path string) (map[string]dbTable, uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var file databaseFile
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber() // keep INT values exact until they're re-cast
+	if err := decoder.Decode(&file); err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", path, err)
+	}
+	if file.Version != databaseFormatVersion {
+		return nil, 0, fmt.Errorf("%s: unsupported format version %d", path, file.Version)
+	}
+
+	loaded := make(map[string]dbTable, len(file.Tables))
+	for _, table := range file.Tables {
+		for _, row := range table.Rows {
+			for _, col := range table.Columns {
+				val, err := decodeValue(col, row[col.Name])
+				if err != nil {
+					return nil, 0, fmt.Errorf("%s: table %s: %v", path, table.Name, err)
+				}
+				row[col.Name] = val
+			}
+		}
+		if table.Rows == nil {
+			table.Rows = []map[string]interface{}{}
+		}
+		if table.AutoIncrement == nil {
+			table.AutoIncrement = make(map[string]int)
+		}
+		loaded[table.Name] = table
+	}
+	return loaded, file.LastLSN, nil
+}
+
+// decodeValue turns a JSON value back into the Go type rows hold for the
+// column: int for INT, float64 for FLOAT and string for everything else.
+func decodeValue(col dbColumn, raw interface{}) (interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	switch strings.ToUpper(col.Type) {
+	case "INT":
+		n, ok := raw.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("column %s: expected a number, got %v", col.Name, raw)
+		}
+		v, err := strconv.Atoi(n.String())
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		return v, nil
+	case "FLOAT":
+		n, ok := raw.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("column %s: expected a number, got %v", col.Name, raw)
+		}
+		v, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", col.Name, err)
+		}
+		return v, nil
+	default:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("column %s: expected a string, got %v", col.Name, raw)
+		}
+		return s, nil
+	}
+}
+
+// openDatabase loads the saved database, or reports that there is none yet
+// (with no tables) so the caller can seed it.
+func openDatabase(path string) (tables map[string]dbTable, existed bool, lastLSN uint64, err error) {
+	tables, lastLSN, err = loadDatabase(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]dbTable), false, 0, nil
+	}
+	return tables, err == nil, lastLSN, err
+}
@@ -1,46 +1,331 @@
-package main
+package dpsql
 
 // Initialize Imports
 import (
+	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
-func createTableFromAST(createNode *ASTNode) {
+func columnFromAST(column *astNode) dbColumn {
+	newColumn := dbColumn{
+		Name:         column.name,
+		Type:         column._type,
+		Conditions:   append([]string{}, column.constraints...),
+		VarCharLimit: 0,
+	}
+	if column._type == "VARCHAR" {
+		newColumn.VarCharLimit = column.varCharLimit
+	}
+	return newColumn
+}
+
+func (db *DB) createTableFromAST(createNode *astNode) (Result, error) {
 	tableName := createNode.tableName
 	columns := createNode.columns
-	newColumns := make([]Column, len(columns))
+	newColumns := make([]dbColumn, len(columns))
 	for i, column := range columns {
-		newColumns[i] = Column{
-			Name:         column.name,
-			Type:         column._type,
-			Conditions:   []string{},
-			VarCharLimit: 0,
+		newColumns[i] = columnFromAST(column)
+	}
+	if db.tableExists(tableName) {
+		return Result{}, fmt.Errorf("Table %s already exists", tableName)
+	}
+	if err := checkColumnDefinitions(newColumns); err != nil {
+		return Result{}, err
+	}
+	if err := db.checkForeignKeyDefinitions(tableName, newColumns, createNode.foreignKeys); err != nil {
+		return Result{}, err
+	}
+	db.createTable(tableName, newColumns)
+	table := db.tables[tableName]
+	table.ForeignKeys = append([]foreignKey{}, createNode.foreignKeys...)
+	db.tables[tableName] = table
+	return Result{}, nil
+}
+
+// checkColumnDefinitions rejects constraints that can never work: an
+// AUTO_INCREMENT that isn't an INT, BOUNDS on a column that isn't a number,
+// or a CHECK naming an unknown column.
+func checkColumnDefinitions(columns []dbColumn) (err error) {
+	scope := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		scope[col.Name] = nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	for _, col := range columns {
+		if hasCondition(col, "AUTO_INCREMENT") && strings.ToUpper(col.Type) != "INT" {
+			return fmt.Errorf("Column %s: AUTO_INCREMENT needs an INT column", col.Name)
+		}
+		if _, ok := columnBounds(col); ok && strings.ToUpper(col.Type) != "INT" && strings.ToUpper(col.Type) != "FLOAT" {
+			return fmt.Errorf("Column %s: BOUNDS needs an INT or FLOAT column", col.Name)
+		}
+		for _, constraint := range col.Conditions {
+			if strings.HasPrefix(constraint, "CHECK ") {
+				checkReferences("CHECK on column "+col.Name, parsedCheck(constraint), scope)
+			}
+		}
+	}
+	return nil
+}
+
+// checkCache holds parsed CHECK constraints, keyed by their condition string.
+// It is shared by every open DB, hence the lock.
+var (
+	checkCache   = make(map[string]*astNode)
+	checkCacheMu sync.Mutex
+)
+
+// parsedCheck returns the expression of a "CHECK (...)" condition.
+func parsedCheck(condition string) *astNode {
+	checkCacheMu.Lock()
+	defer checkCacheMu.Unlock()
+	if expr, ok := checkCache[condition]; ok {
+		return expr
+	}
+	tokens := tokenize(strings.TrimPrefix(condition, "CHECK "))
+	tokenIndex := 0
+	expr := parseExpression(tokens, &tokenIndex)
+	checkCache[condition] = expr
+	return expr
+}
+
+// checkRowConstraints runs every CHECK constraint of the table against a
+// complete row. As in SQL, only a false result is a violation; NULL passes.
+func checkRowConstraints(columns []dbColumn, row map[string]interface{}) error {
+	for _, col := range columns {
+		for _, constraint := range col.Conditions {
+			if !strings.HasPrefix(constraint, "CHECK ") {
+				continue
+			}
+			result := evalExpression(parsedCheck(constraint), row)
+			if result != nil && !isTruthy(result) {
+				return fmt.Errorf("Column %s violates %s", col.Name, constraint)
+			}
+		}
+	}
+	return nil
+}
+
+// nextAutoIncrement fills in an AUTO_INCREMENT column left out or NULL.
+func nextAutoIncrement(table dbTable, col dbColumn, raw interface{}) interface{} {
+	if raw == nil && hasCondition(col, "AUTO_INCREMENT") {
+		return strconv.Itoa(table.AutoIncrement[col.Name] + 1)
+	}
+	return raw
+}
+
+// columnDefault is the raw value of a column an INSERT leaves out: its
+// DEFAULT, or nil for NULL.
+func columnDefault(col dbColumn) interface{} {
+	for _, constraint := range col.Conditions {
+		if strings.HasPrefix(constraint, "DEFAULT ") {
+			return strings.TrimPrefix(constraint, "DEFAULT ")
+		}
+	}
+	return nil
+}
+
+// bumpAutoIncrement moves AUTO_INCREMENT counters past the values in a row
+// that was just written, including explicitly supplied ones.
+func bumpAutoIncrement(table *dbTable, row map[string]interface{}) {
+	for _, col := range table.Columns {
+		if !hasCondition(col, "AUTO_INCREMENT") {
+			continue
+		}
+		if v, ok := row[col.Name].(int); ok && v > table.AutoIncrement[col.Name] {
+			if table.AutoIncrement == nil {
+				table.AutoIncrement = make(map[string]int)
+			}
+			table.AutoIncrement[col.Name] = v
+		}
+	}
+}
+
+// dropTableFromAST runs DROP TABLE [IF EXISTS].
+func (db *DB) dropTableFromAST(dropNode *astNode) (Result, error) {
+	tableName := dropNode.tableName
+	if !db.tableExists(tableName) {
+		if !dropNode.ifExists {
+			return Result{}, fmt.Errorf("Table %s does not exist", tableName)
+		}
+		return Result{}, nil
+	}
+	if childName, ok := db.referencedBy(tableName); ok {
+		return Result{}, fmt.Errorf("Cannot drop %s: %s has a foreign key referencing it", tableName, childName)
+	}
+	delete(db.tables, tableName)
+	return Result{Message: fmt.Sprintf("Dropped table %s", tableName)}, nil
+}
+
+// alterTableFromAST runs ALTER TABLE ADD/DROP/RENAME COLUMN and RENAME TO,
+// rewriting the row maps to match the new schema.
+func (db *DB) alterTableFromAST(alterNode *astNode) (Result, error) {
+	tableName := alterNode.tableName
+	if !db.tableExists(tableName) {
+		return Result{}, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	table := db.tables[tableName]
+
+	columnIndex := -1
+	if alterNode.alterAction == "DROP COLUMN" || alterNode.alterAction == "RENAME COLUMN" {
+		for i, col := range table.Columns {
+			if col.Name == alterNode.name {
+				columnIndex = i
+				break
+			}
 		}
-		if column._type == "VARCHAR" {
-			newColumns[i].VarCharLimit = column.varCharLimit
+		if columnIndex < 0 {
+			return Result{}, fmt.Errorf("Column %s does not exist in %s", alterNode.name, tableName)
 		}
 	}
-	createTable(tableName, newColumns)
+
+	var message string
+	switch alterNode.alterAction {
+	case "ADD COLUMN":
+		newColumn := columnFromAST(alterNode.columns[0])
+		for _, col := range table.Columns {
+			if col.Name == newColumn.Name {
+				return Result{}, fmt.Errorf("Column %s already exists in %s", newColumn.Name, tableName)
+			}
+		}
+
+		newColumns := append(append([]dbColumn{}, table.Columns...), newColumn)
+		if err := checkColumnDefinitions(newColumns); err != nil {
+			return Result{}, err
+		}
+		newKeys := alterNode.columns[0].foreignKeys
+		if err := db.checkForeignKeyDefinitions(tableName, newColumns, newKeys); err != nil {
+			return Result{}, err
+		}
+		withKeys := dbTable{Name: tableName, ForeignKeys: newKeys}
+
+		// back-fill existing rows the way an INSERT that left the column out
+		// would: DEFAULT, AUTO_INCREMENT or NULL, then the constraints
+		filled := make([]map[string]interface{}, len(table.Rows))
+		counter := 0
+		for i, row := range table.Rows {
+			raw := columnDefault(newColumn)
+			if hasCondition(newColumn, "AUTO_INCREMENT") {
+				counter++
+				raw = strconv.Itoa(counter)
+			}
+			val, err := prepareColumnValue(filled[:i], newColumn, raw, -1)
+			if err != nil {
+				return Result{}, err
+			}
+			filled[i] = map[string]interface{}{newColumn.Name: val}
+
+			withNew := make(map[string]interface{}, len(row)+1)
+			for k, v := range row {
+				withNew[k] = v
+			}
+			withNew[newColumn.Name] = val
+			if err := checkRowConstraints([]dbColumn{newColumn}, withNew); err != nil {
+				return Result{}, err
+			}
+			if err := db.checkForeignKeys(withKeys, withNew, table.Rows); err != nil {
+				return Result{}, err
+			}
+		}
+		for i, row := range table.Rows {
+			row[newColumn.Name] = filled[i][newColumn.Name]
+		}
+		table.Columns = newColumns
+		table.ForeignKeys = append(table.ForeignKeys, newKeys...)
+		if counter > 0 {
+			if table.AutoIncrement == nil {
+				table.AutoIncrement = make(map[string]int)
+			}
+			table.AutoIncrement[newColumn.Name] = counter
+		}
+		message = fmt.Sprintf("Added column %s to %s", newColumn.Name, tableName)
+
+	case "DROP COLUMN":
+		if len(table.Columns) == 1 {
+			return Result{}, fmt.Errorf("Cannot drop %s, the only column of %s", alterNode.name, tableName)
+		}
+		if fk, ok := db.foreignKeyUsesColumn(tableName, alterNode.name); ok {
+			return Result{}, fmt.Errorf("Cannot drop %s: it is used by %s", alterNode.name, fk)
+		}
+		for _, col := range table.Columns {
+			if col.Name == alterNode.name {
+				continue
+			}
+			for _, constraint := range col.Conditions {
+				if strings.HasPrefix(constraint, "CHECK ") && referencesColumn(parsedCheck(constraint), alterNode.name) {
+					return Result{}, fmt.Errorf("Cannot drop %s: it is used by %s on column %s", alterNode.name, constraint, col.Name)
+				}
+			}
+		}
+		table.Columns = append(table.Columns[:columnIndex:columnIndex], table.Columns[columnIndex+1:]...)
+		for _, row := range table.Rows {
+			delete(row, alterNode.name)
+		}
+		message = fmt.Sprintf("Dropped column %s from %s", alterNode.name, tableName)
+
+	case "RENAME COLUMN":
+		for _, col := range table.Columns {
+			if col.Name == alterNode.newName {
+				return Result{}, fmt.Errorf("Column %s already exists in %s", alterNode.newName, tableName)
+			}
+		}
+		table.Columns[columnIndex].Name = alterNode.newName
+		// CHECKs name columns in their text, so they are rewritten too
+		for i, col := range table.Columns {
+			conditions := make([]string, len(col.Conditions))
+			for j, constraint := range col.Conditions {
+				conditions[j] = constraint
+				if strings.HasPrefix(constraint, "CHECK ") {
+					renamed := renameColumnReferences(parsedCheck(constraint), alterNode.name, alterNode.newName)
+					conditions[j] = "CHECK " + formatExpression(renamed)
+				}
+			}
+			table.Columns[i].Conditions = conditions
+		}
+		for _, row := range table.Rows {
+			row[alterNode.newName] = row[alterNode.name]
+			delete(row, alterNode.name)
+		}
+		db.renameForeignKeyColumn(tableName, alterNode.name, alterNode.newName)
+		message = fmt.Sprintf("Renamed column %s to %s in %s", alterNode.name, alterNode.newName, tableName)
+
+	case "RENAME TO":
+		if db.tableExists(alterNode.newName) {
+			return Result{}, fmt.Errorf("Table %s already exists", alterNode.newName)
+		}
+		delete(db.tables, tableName)
+		table.Name = alterNode.newName
+		db.tables[alterNode.newName] = table
+		db.renameForeignKeyTable(tableName, alterNode.newName)
+		return Result{Message: fmt.Sprintf("Renamed table %s to %s", tableName, alterNode.newName)}, nil
+	}
+
+	db.tables[tableName] = table
+	return Result{Message: message}, nil
 }
 
-func insertIntoFromAST(insertNode *ASTNode) {
+func (db *DB) insertIntoFromAST(insertNode *astNode) (Result, error) {
 	tableName := insertNode.tableName
-	if !tableExists(tableName) {
-		fmt.Printf("Table %s does not exist\n", tableName)
-		return
+	if !db.tableExists(tableName) {
+		return Result{}, fmt.Errorf("Table %s does not exist", tableName)
 	}
 
-	table := database[tableName]
+	table := db.tables[tableName]
 	columnNames := insertNode.columnNames
 	columnValues := insertNode.columnValues
 
 	// Support implicit column list
 	if len(columnNames) == 0 {
 		if len(columnValues) != len(table.Columns) {
-			fmt.Println("Value count doesn't match number of table columns")
-			return
+			return Result{}, errors.New("Value count doesn't match number of table columns")
 		}
 		for _, col := range table.Columns {
 			columnNames = append(columnNames, col.Name)
@@ -48,163 +333,504 @@ func insertIntoFromAST(insertNode *ASTNode) {
 	}
 
 	if len(columnNames) != len(columnValues) {
-		fmt.Println("Mismatch between number of column names and values")
-		return
+		return Result{}, errors.New("Mismatch between number of column names and values")
+	}
+
+	for _, colName := range columnNames {
+		found := false
+		for _, col := range table.Columns {
+			if col.Name == colName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return Result{}, fmt.Errorf("Column %s does not exist in %s", colName, tableName)
+		}
 	}
 
 	newRow := make(map[string]interface{})
 
+	// every column goes through its constraints, including ones left out of
+	// the column list (they pick up DEFAULT/AUTO_INCREMENT or fail NOT NULL)
 	for _, col := range table.Columns {
+		raw := columnDefault(col)
 		for j, colName := range columnNames {
-			if col.Name != colName {
-				continue
+			if col.Name == colName {
+				raw = columnValues[j]
+				break
 			}
+		}
 
-			val := strings.TrimSpace(columnValues[j])
+		val, err := prepareColumnValue(table.Rows, col, nextAutoIncrement(table, col, raw), -1)
+		if err != nil {
+			return Result{}, err
+		}
+		newRow[col.Name] = val
+	}
+	if err := checkRowConstraints(table.Columns, newRow); err != nil {
+		return Result{}, err
+	}
+	if err := db.checkForeignKeys(table, newRow, append(table.Rows[:len(table.Rows):len(table.Rows)], newRow)); err != nil {
+		return Result{}, err
+	}
 
-			// Constraint checks
-			for _, constraint := range col.Conditions {
-				if constraint == "PRIMARY KEY" || constraint == "UNIQUE" {
-					if !checkUnique(tableName, col.Name, val) {
-						fmt.Printf("Column %s must be unique\n", col.Name)
-						return
-					}
-				}
-				if strings.HasPrefix(constraint, "DEFAULT") && val == "" {
-					val = strings.TrimPrefix(constraint, "DEFAULT ")
-				}
-				if constraint == "NOT NULL" && val == "" {
-					fmt.Printf("Column %s cannot be NULL\n", col.Name)
-					return
-				}
+	bumpAutoIncrement(&table, newRow)
+	table.Rows = append(table.Rows, newRow)
+	db.tables[tableName] = table
+	return Result{RowsAffected: 1, Message: fmt.Sprintf("Inserted row into %s", tableName)}, nil
+}
+
+// prepareColumnValue applies a column's constraints and type to a raw value on
+// its way into rows: NOT NULL, casting to INT/FLOAT, the VARCHAR length
+// limit and finally PRIMARY KEY/UNIQUE. raw is the value's text, or nil for
+// NULL; a column left out of an INSERT has had its DEFAULT put in already.
+// skipRow is the index of the row being overwritten, or -1.
+func prepareColumnValue(rows []map[string]interface{}, col dbColumn, raw interface{}, skipRow int) (interface{}, error) {
+	if raw == nil {
+		for _, constraint := range col.Conditions {
+			if constraint == "NOT NULL" || constraint == "PRIMARY KEY" {
+				return nil, fmt.Errorf("Column %s cannot be NULL", col.Name)
 			}
+		}
+		return nil, nil
+	}
+	val := strings.TrimSpace(raw.(string))
 
-			// Type casting
-			switch strings.ToUpper(col.Type) {
-			case "INT":
-				if val != "" {
-					intVal, err := strconv.Atoi(val)
-					if err != nil {
-						fmt.Printf("Column %s must be an integer\n", col.Name)
-						return
-					}
-					newRow[col.Name] = intVal
-				} else {
-					newRow[col.Name] = nil
-				}
-			case "FLOAT":
-				if val != "" {
-					floatVal, err := strconv.ParseFloat(val, 64)
-					if err != nil {
-						fmt.Printf("Column %s must be a float\n", col.Name)
-						return
-					}
-					newRow[col.Name] = floatVal
-				} else {
-					newRow[col.Name] = nil
-				}
-			default:
-				if strings.HasPrefix(strings.ToUpper(col.Type), "VARCHAR") {
-					maxLen := col.VarCharLimit
-					if len(val) > maxLen {
-						fmt.Printf("Column %s exceeds VARCHAR(%d)\n", col.Name, maxLen)
-						return
-					}
-					newRow[col.Name] = val
-				} else {
-					newRow[col.Name] = val
+	// Type casting
+	var typed interface{}
+	switch strings.ToUpper(col.Type) {
+	case "INT":
+		intVal, err := strconv.Atoi(val)
+		if err != nil {
+			return nil, fmt.Errorf("Column %s must be an integer", col.Name)
+		}
+		typed = intVal
+	case "FLOAT":
+		floatVal, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Column %s must be a float", col.Name)
+		}
+		// NaN and ±Inf have no JSON form, so the database couldn't be saved
+		if math.IsNaN(floatVal) || math.IsInf(floatVal, 0) {
+			return nil, fmt.Errorf("Column %s must be a finite float, got %s", col.Name, val)
+		}
+		typed = floatVal
+	default:
+		if strings.HasPrefix(strings.ToUpper(col.Type), "VARCHAR") {
+			maxLen := col.VarCharLimit
+			if len(val) > maxLen {
+				return nil, fmt.Errorf("Column %s exceeds VARCHAR(%d)", col.Name, maxLen)
+			}
+		}
+		typed = val
+	}
+
+	for _, constraint := range col.Conditions {
+		if constraint == "PRIMARY KEY" || constraint == "UNIQUE" {
+			if typed != nil && !checkUnique(rows, col.Name, typed, skipRow) {
+				return nil, fmt.Errorf("Column %s must be unique", col.Name)
+			}
+		}
+	}
+
+	return typed, nil
+}
+
+// updateFromAST runs UPDATE ... SET ... [WHERE ...]. Every matching row is
+// checked before any is written, so a bad value leaves the table untouched.
+func (db *DB) updateFromAST(updateNode *astNode) (Result, error) {
+	tableName := updateNode.tableName
+	if !db.tableExists(tableName) {
+		return Result{}, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	table := db.tables[tableName]
+
+	setColumns := make([]dbColumn, len(updateNode.columnNames))
+	for i, name := range updateNode.columnNames {
+		found := false
+		for _, col := range table.Columns {
+			if col.Name == name {
+				setColumns[i] = col
+				found = true
+				break
+			}
+		}
+		if !found {
+			return Result{}, fmt.Errorf("Column %s does not exist in %s", name, tableName)
+		}
+	}
+
+	// stage every new row first: uniqueness holds for the table as the
+	// UPDATE leaves it, so SET id = id + 1 may pass through values in use
+	newRows := make([]map[string]interface{}, len(table.Rows))
+	copy(newRows, table.Rows)
+	updated := 0
+	for ri, row := range table.Rows {
+		if !rowMatches(updateNode.whereClause, row) {
+			continue
+		}
+		newRow := make(map[string]interface{}, len(row))
+		for k, v := range row {
+			newRow[k] = v
+		}
+		for i, col := range setColumns {
+			// SET expressions see the row as it was before the update
+			var raw interface{}
+			if v := evalExpression(updateNode.setValues[i], row); v != nil {
+				raw = fmt.Sprint(v)
+			}
+			val, err := prepareColumnValue(nil, col, raw, -1)
+			if err != nil {
+				return Result{}, err
+			}
+			newRow[col.Name] = val
+		}
+		if err := checkRowConstraints(table.Columns, newRow); err != nil {
+			return Result{}, err
+		}
+		if err := db.checkKeyChanges(tableName, row, newRow); err != nil {
+			return Result{}, err
+		}
+		newRows[ri] = newRow
+		updated++
+	}
+	for _, col := range setColumns {
+		if !hasCondition(col, "PRIMARY KEY") && !hasCondition(col, "UNIQUE") {
+			continue
+		}
+		seen := make(map[interface{}]bool, len(newRows))
+		for _, row := range newRows {
+			if v := row[col.Name]; v != nil {
+				if seen[v] {
+					return Result{}, fmt.Errorf("Column %s must be unique", col.Name)
 				}
+				seen[v] = true
 			}
 		}
 	}
+	// foreign keys are checked once every row is staged, so a self-referencing
+	// table can point at values set by the same UPDATE
+	for _, row := range newRows {
+		if err := db.checkForeignKeys(table, row, newRows); err != nil {
+			return Result{}, err
+		}
+	}
 
-	table.Rows = append(table.Rows, newRow)
-	database[tableName] = table
-	fmt.Printf("Inserted row into %s\n", tableName)
+	for _, row := range newRows {
+		bumpAutoIncrement(&table, row)
+	}
+	table.Rows = newRows
+	db.tables[tableName] = table
+	return Result{RowsAffected: int64(updated), Message: fmt.Sprintf("Updated %d rows in %s", updated, tableName)}, nil
+}
+
+// deleteFromAST runs DELETE FROM ... [WHERE ...].
+func (db *DB) deleteFromAST(deleteNode *astNode) (Result, error) {
+	tableName := deleteNode.tableName
+	if !db.tableExists(tableName) {
+		return Result{}, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	table := db.tables[tableName]
+
+	matched := make([]int, 0)
+	for ri, row := range table.Rows {
+		if rowMatches(deleteNode.whereClause, row) {
+			matched = append(matched, ri)
+		}
+	}
+
+	// follow ON DELETE actions before touching anything, so a RESTRICT
+	// anywhere down the chain leaves every table as it was
+	plan, err := db.planDelete(tableName, matched)
+	if err != nil {
+		return Result{}, err
+	}
+	deleted := plan.apply(db)
+
+	lines := []string{fmt.Sprintf("Deleted %d rows from %s", deleted[tableName], tableName)}
+	for _, name := range plan.order {
+		if name != tableName && deleted[name] > 0 {
+			lines = append(lines, fmt.Sprintf("Deleted %d rows from %s (ON DELETE CASCADE)", deleted[name], name))
+		}
+	}
+	return Result{RowsAffected: int64(deleted[tableName]), Message: strings.Join(lines, "\n")}, nil
+}
+
+// truncateFromAST runs TRUNCATE TABLE, removing every row but keeping the
+// schema.
+func (db *DB) truncateFromAST(truncateNode *astNode) (Result, error) {
+	tableName := truncateNode.tableName
+	if !db.tableExists(tableName) {
+		return Result{}, fmt.Errorf("Table %s does not exist", tableName)
+	}
+	if childName, ok := db.referencedBy(tableName); ok {
+		return Result{}, fmt.Errorf("Cannot truncate %s: %s has a foreign key referencing it", tableName, childName)
+	}
+	table := db.tables[tableName]
+	deleted := len(table.Rows)
+
+	table.Rows = []map[string]interface{}{}
+	table.AutoIncrement = make(map[string]int)
+	db.tables[tableName] = table
+	return Result{RowsAffected: int64(deleted), Message: fmt.Sprintf("Truncated %s (%d rows deleted)", tableName, deleted)}, nil
 }
 
 func isGroupByColumn(columnTypes []columnType, columnNames []string, columnName string) bool {
 	for i, name := range columnNames {
 		if name == columnName {
-			return columnTypes[i] == COLUMN_TYPE_GROUP_BY
+			return columnTypes[i] == columnTypeGroupBy
 		}
 	}
 	return false
 }
 
+// fromSource is one table of a FROM clause together with the name its
+// columns are qualified with.
+type fromSource struct {
+	qualifier string
+	table     dbTable
+}
+
+// scopeRow copies a table row into a joined row: every column under
+// qualifier.column, and also under its bare name unless that name is
+// ambiguous across the FROM clause. A nil row yields the NULL padding used by
+// outer joins.
+func scopeRow(dst map[string]interface{}, source fromSource, row map[string]interface{}, ambiguous map[string]bool) {
+	for _, col := range source.table.Columns {
+		var val interface{}
+		if row != nil {
+			val = row[col.Name]
+		}
+		dst[source.qualifier+"."+col.Name] = val
+		if !ambiguous[col.Name] {
+			dst[col.Name] = val
+		}
+	}
+}
+
+// buildSourceRows evaluates the FROM clause, joins included, and returns the
+// rows the rest of the SELECT runs over along with their columns.
+func (db *DB) buildSourceRows(selectNode *astNode) ([]map[string]interface{}, []dbColumn) {
+	sources := make([]fromSource, 0, len(selectNode.joins)+1)
+	addSource := func(tableName string, alias string) {
+		if !db.tableExists(tableName) {
+			panic(fmt.Sprintf("Table %s does not exist", tableName))
+		}
+		qualifier := alias
+		if qualifier == "" {
+			qualifier = tableName
+		}
+		for _, s := range sources {
+			if s.qualifier == qualifier {
+				panic(fmt.Sprintf("Table %s appears twice in FROM; give it an alias", qualifier))
+			}
+		}
+		sources = append(sources, fromSource{qualifier: qualifier, table: db.tables[tableName]})
+	}
+	addSource(selectNode.tableName, selectNode.tableAlias)
+	for _, join := range selectNode.joins {
+		addSource(join.tableName, join.tableAlias)
+	}
+
+	// a bare column name is only usable if exactly one table has it
+	seen := make(map[string]int)
+	for _, s := range sources {
+		for _, col := range s.table.Columns {
+			seen[col.Name]++
+		}
+	}
+	ambiguous := make(map[string]bool)
+	for name, n := range seen {
+		if n > 1 {
+			ambiguous[name] = true
+		}
+	}
+
+	columns := make([]dbColumn, 0)
+	for _, s := range sources {
+		for _, col := range s.table.Columns {
+			qualified := col
+			qualified.Name = s.qualifier + "." + col.Name
+			columns = append(columns, qualified)
+			if !ambiguous[col.Name] {
+				columns = append(columns, col)
+			}
+		}
+	}
+
+	rows := make([]map[string]interface{}, 0, len(sources[0].table.Rows))
+	for _, srcRow := range sources[0].table.Rows {
+		row := make(map[string]interface{}, 2*len(sources[0].table.Columns))
+		scopeRow(row, sources[0], srcRow, ambiguous)
+		rows = append(rows, row)
+	}
+
+	scope := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		scope[col.Name] = nil
+	}
+
+	for ji, join := range selectNode.joins {
+		right := sources[ji+1]
+		checkReferences("ON", join.onClause, scope)
+
+		joined := make([]map[string]interface{}, 0, len(rows))
+		rightMatched := make([]bool, len(right.table.Rows))
+		for _, leftRow := range rows {
+			leftMatched := false
+			for ri, rightRow := range right.table.Rows {
+				row := make(map[string]interface{}, len(leftRow)+2*len(right.table.Columns))
+				for k, v := range leftRow {
+					row[k] = v
+				}
+				scopeRow(row, right, rightRow, ambiguous)
+				if rowMatches(join.onClause, row) {
+					joined = append(joined, row)
+					leftMatched = true
+					rightMatched[ri] = true
+				}
+			}
+			if !leftMatched && (join.joinType == "LEFT" || join.joinType == "FULL") {
+				row := make(map[string]interface{}, len(leftRow)+2*len(right.table.Columns))
+				for k, v := range leftRow {
+					row[k] = v
+				}
+				scopeRow(row, right, nil, ambiguous)
+				joined = append(joined, row)
+			}
+		}
+		if join.joinType == "RIGHT" || join.joinType == "FULL" {
+			for ri, rightRow := range right.table.Rows {
+				if rightMatched[ri] {
+					continue
+				}
+				row := make(map[string]interface{})
+				for _, left := range sources[:ji+1] {
+					scopeRow(row, left, nil, ambiguous)
+				}
+				scopeRow(row, right, rightRow, ambiguous)
+				joined = append(joined, row)
+			}
+		}
+		rows = joined
+	}
+
+	for i, name := range selectNode.columnNames {
+		if name == "*" && selectNode.columnTypes[i] == columnTypeCount {
+			continue // COUNT(*)
+		}
+		if _, ok := scope[name]; !ok {
+			panic(fmt.Sprintf("SELECT: unknown or ambiguous column %q", name))
+		}
+	}
+	if selectNode.whereClause != nil {
+		checkReferences("WHERE", selectNode.whereClause, scope)
+	}
+
+	return rows, columns
+}
+
 // ------------------- SELECT with AVG support -------------------
-func selectFromAST(selectNode *ASTNode) Table {
-	tableName := selectNode.tableName
-	srcTable := database[tableName]
+func (db *DB) selectFromAST(selectNode *astNode) (dbTable, map[string]aggregateSensitivity) {
+	srcRows, srcColumns := db.buildSourceRows(selectNode)
 
-	// Build schema: one Column per selectNode.column + a hidden "count" for AVG
-	newCols := make([]Column, len(selectNode.columnNames)+1)
+	// Build schema: one Column per selectNode.column + a hidden "count"
+	newCols := make([]dbColumn, len(selectNode.columnNames)+1)
 	for i, origName := range selectNode.columnNames {
 		ct := selectNode.columnTypes[i]
 
 		// decide visibility
-		vis := (ct == COLUMN_TYPE_GROUP_BY ||
-			ct == COLUMN_TYPE_NORMAL ||
-			ct == COLUMN_TYPE_COUNT ||
-			ct == COLUMN_TYPE_MAX ||
-			ct == COLUMN_TYPE_MIN ||
-			ct == COLUMN_TYPE_SUM ||
-			ct == COLUMN_TYPE_AVG)
-
-		// decide type
-		typ := ""
-		if ct == COLUMN_TYPE_GROUP_BY {
-			for _, c := range srcTable.Columns {
+		vis := (ct == columnTypeGroupBy ||
+			ct == columnTypeNormal ||
+			ct == columnTypeCount ||
+			ct == columnTypeMax ||
+			ct == columnTypeMin ||
+			ct == columnTypeSum ||
+			ct == columnTypeAvg) && !selectNode.columnHidden[i]
+
+		// decide type: aggregates are always FLOAT, the rest keep the type
+		// of the column they come from
+		typ := "FLOAT"
+		if ct == columnTypeGroupBy || ct == columnTypeNormal {
+			for _, c := range srcColumns {
 				if c.Name == origName {
 					typ = c.Type
 					break
 				}
 			}
-		} else {
-			typ = "float64"
 		}
 
 		// compute alias: user‐supplied if given, otherwise for aggregates use func_origName
 		alias := selectNode.columnAliases[i]
 		// fmt.Println("selectFromAST: origName=%s, typ=%s, vis=%t, alias=%s", origName, typ, vis, alias)
-		if alias == "" && ct != COLUMN_TYPE_NORMAL && ct != COLUMN_TYPE_GROUP_BY {
+		if alias == "" && ct != columnTypeNormal && ct != columnTypeGroupBy {
+			// leave the table qualifier out of generated names (m.age -> count_age)
+			baseName := origName
+			if dot := strings.LastIndex(baseName, "."); dot >= 0 {
+				baseName = baseName[dot+1:]
+			}
 			switch ct {
-			case COLUMN_TYPE_SUM:
-				alias = "sum_" + origName
-			case COLUMN_TYPE_AVG:
-				alias = "avg_" + origName
-			case COLUMN_TYPE_MIN:
-				alias = "min_" + origName
-			case COLUMN_TYPE_MAX:
-				alias = "max_" + origName
-			case COLUMN_TYPE_COUNT:
-				alias = "count_" + origName
+			case columnTypeSum:
+				alias = "sum_" + baseName
+			case columnTypeAvg:
+				alias = "avg_" + baseName
+			case columnTypeMin:
+				alias = "min_" + baseName
+			case columnTypeMax:
+				alias = "max_" + baseName
+			case columnTypeCount:
+				alias = "count_" + baseName
 			}
 		}
 
-		newCols[i] = Column{
-			Name:           origName,
+		// aggregates are keyed like COUNT(x) so two aggregates over the same
+		// column (or a group key and its COUNT) don't collide in the row map
+		name := origName
+		if ct != columnTypeNormal && ct != columnTypeGroupBy {
+			name = aggregateKey(columnTypeToFunctionName(ct), origName)
+		}
+
+		newCols[i] = dbColumn{
+			Name:           name,
 			Type:           typ,
 			Conditions:     nil,
 			VarCharLimit:   0,
-			FunctionResult: ct != COLUMN_TYPE_NORMAL && ct != COLUMN_TYPE_GROUP_BY,
+			FunctionResult: ct != columnTypeNormal && ct != columnTypeGroupBy,
 			Visible:        vis,
 			Alias:          alias,
 		}
 	}
 	// Hidden global count for AVG denominator
 	countIdx := len(selectNode.columnNames)
-	newCols[countIdx] = Column{
+	newCols[countIdx] = dbColumn{
 		Name:           "count",
-		Type:           "float64",
+		Type:           "FLOAT",
 		Conditions:     nil,
 		VarCharLimit:   0,
 		FunctionResult: true,
 		Visible:        false,
 		Alias:          "count",
 	}
+	// each AVG is divided by the number of rows its column isn't NULL in,
+	// COUNT(x), kept as a hidden column unless the query selects it too
+	hiddenCounts := map[string]string{}
+	for i, ct := range selectNode.columnTypes {
+		if ct != columnTypeAvg {
+			continue
+		}
+		key := aggregateKey("COUNT", selectNode.columnNames[i])
+		selected := false
+		for _, c := range newCols {
+			selected = selected || c.Name == key
+		}
+		if !selected {
+			newCols = append(newCols, dbColumn{Name: key, Type: "FLOAT", FunctionResult: true, Alias: key})
+			hiddenCounts[key] = selectNode.columnNames[i]
+		}
+	}
 
-	result := Table{
+	result := dbTable{
 		Name:    "result",
 		Columns: newCols,
 		Rows:    []map[string]interface{}{},
@@ -213,106 +839,558 @@ func selectFromAST(selectNode *ASTNode) Table {
 	// figure out which cols are GROUP_BY
 	groupByIdx := []int{}
 	for i, ct := range selectNode.columnTypes {
-		if ct == COLUMN_TYPE_GROUP_BY {
+		if ct == columnTypeGroupBy {
 			groupByIdx = append(groupByIdx, i)
 		}
 	}
 
-	// aggregate rows
-	for _, srcRow := range srcTable.Rows {
-		// find matching bucket
-		bucket := -1
-		for ri, outRow := range result.Rows {
-			match := true
-			for _, gi := range groupByIdx {
-				orig := selectNode.columnNames[gi]
-				if outRow[orig] != srcRow[orig] {
-					match = false
+	// SUM, AVG, MIN and MAX read their column clamped to its BOUNDS, which
+	// is also what sets how much noise they need
+	sensitivities := map[string]aggregateSensitivity{"count": {ct: columnTypeCount}}
+	bounds := make([]valueBounds, len(selectNode.columnNames))
+	for i, ct := range selectNode.columnTypes {
+		if !newCols[i].FunctionResult {
+			continue
+		}
+		sensitivity := aggregateSensitivity{ct: ct}
+		if ct != columnTypeCount {
+			name := selectNode.columnNames[i]
+			for _, c := range srcColumns {
+				if c.Name == name {
+					bounds[i], _ = columnBounds(c)
 					break
 				}
 			}
-			if match {
-				bucket = ri
-				break
+			if !bounds[i].set {
+				panic(fmt.Sprintf("%s(%s) needs BOUNDS on %s to work out how much noise to add",
+					columnTypeToFunctionName(ct), name, name))
+			}
+			sensitivity.bounds = bounds[i]
+		}
+		if ct == columnTypeAvg {
+			sensitivity.count = aggregateKey("COUNT", selectNode.columnNames[i])
+		}
+		sensitivities[newCols[i].Name] = sensitivity
+	}
+	for key := range hiddenCounts {
+		sensitivities[key] = aggregateSensitivity{ct: columnTypeCount}
+	}
+	// the hidden count only leaves through a HAVING or ORDER BY that names
+	// it or the rows k-anonymity keeps; otherwise it isn't noised and
+	// takes no share of ε
+	if !needsHiddenCount(selectNode) && db.opts.K <= 0 {
+		delete(sensitivities, "count")
+	}
+	for _, sensitivity := range sensitivities {
+		if sensitivity.ct == columnTypeSum || sensitivity.ct == columnTypeAvg || sensitivity.ct == columnTypeCount {
+			db.checkJoinsMatchOnce(selectNode)
+			break
+		}
+	}
+
+	// aggregate rows
+	buckets := newGroupTrie()
+
+	// without GROUP BY, aggregates answer with one row even when WHERE
+	// matches nothing: no row at all would tell that nobody matches, whatever
+	// the noise
+	if len(groupByIdx) == 0 && len(sensitivities) > 0 {
+		empty := map[string]interface{}{"count": float64(0)}
+		for i, ct := range selectNode.columnTypes {
+			switch ct {
+			case columnTypeSum, columnTypeAvg, columnTypeCount:
+				empty[newCols[i].Name] = float64(0)
+			case columnTypeMin, columnTypeMax:
+				empty[newCols[i].Name] = nil
 			}
 		}
+		for key := range hiddenCounts {
+			empty[key] = float64(0)
+		}
+		buckets.bucket = 0
+		result.Rows = append(result.Rows, empty)
+	}
+	for _, srcRow := range srcRows {
+		if !rowMatches(selectNode.whereClause, srcRow) {
+			continue
+		}
+
+		// find matching bucket
+		node := buckets
+		for _, gi := range groupByIdx {
+			node = node.child(srcRow[selectNode.columnNames[gi]])
+		}
+		bucket := node.bucket
 
 		if bucket >= 0 {
 			// update aggregates
 			outRow := result.Rows[bucket]
 			for i, ct := range selectNode.columnTypes {
-				alias := selectNode.columnNames[i] // columnAliases[i]
+				key := newCols[i].Name
 				switch ct {
-				case COLUMN_TYPE_SUM, COLUMN_TYPE_AVG:
-					outRow[alias] = toFloat64(outRow[alias]) + toFloat64(srcRow[selectNode.columnNames[i]])
-				case COLUMN_TYPE_COUNT:
-					outRow[alias] = toFloat64(outRow[alias]) + 1
-				case COLUMN_TYPE_MIN:
-					outRow[alias] = min(outRow[alias], srcRow[selectNode.columnNames[i]])
-				case COLUMN_TYPE_MAX:
-					outRow[alias] = max(outRow[alias], srcRow[selectNode.columnNames[i]])
+				case columnTypeSum, columnTypeAvg:
+					outRow[key] = toFloat64(outRow[key]) + toFloat64(bounds[i].clamp(srcRow[selectNode.columnNames[i]]))
+				case columnTypeCount:
+					outRow[key] = toFloat64(outRow[key]) + countOf(selectNode.columnNames[i], srcRow)
+				case columnTypeMin:
+					outRow[key] = min(outRow[key], bounds[i].clamp(srcRow[selectNode.columnNames[i]]))
+				case columnTypeMax:
+					outRow[key] = max(outRow[key], bounds[i].clamp(srcRow[selectNode.columnNames[i]]))
+				case columnTypeNormal:
+					// the first row's, as when the bucket is created
+					if _, ok := outRow[key]; !ok {
+						outRow[key] = srcRow[selectNode.columnNames[i]]
+					}
 				}
 			}
+			for key, name := range hiddenCounts {
+				outRow[key] = toFloat64(outRow[key]) + countOf(name, srcRow)
+			}
 			// always bump count
 			outRow["count"] = toFloat64(outRow["count"]) + 1
 		} else {
 			// first time: initialize a new bucket
 			newRow := make(map[string]interface{}, len(newCols))
 			for i, ct := range selectNode.columnTypes {
-				alias := selectNode.columnNames[i]
+				key := newCols[i].Name
 				val := srcRow[selectNode.columnNames[i]]
 				switch ct {
-				case COLUMN_TYPE_SUM, COLUMN_TYPE_AVG:
-					newRow[alias] = toFloat64(val)
-				case COLUMN_TYPE_COUNT:
-					newRow[alias] = float64(1)
-				case COLUMN_TYPE_MIN, COLUMN_TYPE_MAX:
-					newRow[alias] = val
+				case columnTypeSum, columnTypeAvg:
+					newRow[key] = toFloat64(bounds[i].clamp(val))
+				case columnTypeCount:
+					newRow[key] = countOf(selectNode.columnNames[i], srcRow)
+				case columnTypeMin, columnTypeMax:
+					newRow[key] = bounds[i].clamp(val)
 				default: // GROUP_BY or NORMAL
-					newRow[alias] = val
+					newRow[key] = val
 				}
 			}
+			for key, name := range hiddenCounts {
+				newRow[key] = countOf(name, srcRow)
+			}
 			newRow["count"] = float64(1)
+			node.bucket = len(result.Rows)
 			result.Rows = append(result.Rows, newRow)
 		}
 	}
 
-	// now, post‑process AVG columns: sum/count → avg
-	for _, row := range result.Rows {
-		for i, ct := range selectNode.columnTypes {
-			if ct == COLUMN_TYPE_AVG {
-				alias := selectNode.columnNames[i]
-				sum := toFloat64(row[alias])
-				cnt := toFloat64(row["count"])
-				if cnt != 0 {
-					row[alias] = sum / cnt
-				} else {
-					row[alias] = float64(0)
+	// a bad name in HAVING or ORDER BY must fail before the query is
+	// charged, and even when there are no rows to evaluate it on
+	checkResultReferences(result, selectNode)
+
+	// AVG columns still hold their sum: the average is taken once the sum
+	// and its COUNT have their noise
+	return result, sensitivities
+}
+
+// groupTrie maps group-by values to the index of their row in the result,
+// one level per GROUP BY column. Keys are the typed cell values themselves,
+// so 1 and "1" stay apart exactly as they did when buckets were compared with
+// ==, and finding a row's bucket costs one map lookup per group column.
+type groupTrie struct {
+	bucket   int // -1 until a row reaches this node
+	children map[interface{}]*groupTrie
+}
+
+func newGroupTrie() *groupTrie {
+	return &groupTrie{bucket: -1}
+}
+
+// child returns the node below t for value, creating it if needed.
+func (t *groupTrie) child(value interface{}) *groupTrie {
+	if t.children == nil {
+		t.children = make(map[interface{}]*groupTrie)
+	}
+	next, ok := t.children[value]
+	if !ok {
+		next = newGroupTrie()
+		t.children[value] = next
+	}
+	return next
+}
+
+// checkJoinsMatchOnce panics unless every row of the FROM table ends up in
+// at most one joined row. The sensitivity of SUM, AVG and COUNT assumes each
+// individual, a row of the FROM table, adds one row to the aggregate; a join
+// that can match a row several times would multiply what it adds. So each
+// joined table must be looked up by one of its PRIMARY KEY or UNIQUE
+// columns: ON has to include an = between such a column and a column of the
+// tables before it. The joined tables themselves are taken to be public.
+// RIGHT and FULL joins are refused outright: a new FROM row that matches a
+// joined row also takes away that row's NULL-padded one, which may sit in
+// another group, so it moves the aggregates twice as far.
+func (db *DB) checkJoinsMatchOnce(selectNode *astNode) {
+	if len(selectNode.joins) == 0 {
+		return
+	}
+	seen := make(map[string]int)
+	for _, name := range append([]string{selectNode.tableName}, joinTableNames(selectNode)...) {
+		for _, col := range db.tables[name].Columns {
+			seen[col.Name]++
+		}
+	}
+	for _, join := range selectNode.joins {
+		qualifier := join.tableAlias
+		if qualifier == "" {
+			qualifier = join.tableName
+		}
+		// the columns of the joined table, by every name ON can use for them
+		right := make(map[string]dbColumn)
+		for _, col := range db.tables[join.tableName].Columns {
+			right[qualifier+"."+col.Name] = col
+			if seen[col.Name] == 1 {
+				right[col.Name] = col
+			}
+		}
+		if join.joinType == "RIGHT" || join.joinType == "FULL" {
+			panic(fmt.Sprintf("%s JOIN %s: SUM, AVG and COUNT can't be taken over a RIGHT or FULL join, where one row of %s can change two result rows",
+				join.joinType, qualifier, selectNode.tableName))
+		}
+		if !looksUpUnique(join.onClause, right) {
+			panic(fmt.Sprintf("JOIN %s: SUM, AVG and COUNT need each row of %s to match at most one row, so ON must compare a PRIMARY KEY or UNIQUE column of %s with =",
+				qualifier, selectNode.tableName, qualifier))
+		}
+	}
+}
+
+func joinTableNames(selectNode *astNode) []string {
+	names := make([]string, len(selectNode.joins))
+	for i, join := range selectNode.joins {
+		names[i] = join.tableName
+	}
+	return names
+}
+
+// looksUpUnique reports whether a join condition holds for at most one row
+// of the joined table, whose columns are right: one of the conditions ANDed
+// together is unique = other, where other isn't from the joined table.
+func looksUpUnique(on *astNode, right map[string]dbColumn) bool {
+	if on == nil || on.Type != astBinary {
+		return false
+	}
+	switch on.operator {
+	case "AND":
+		return looksUpUnique(on.left, right) || looksUpUnique(on.right, right)
+	case "=":
+		isUnique := func(side *astNode) bool {
+			col, ok := right[side.columnName]
+			return side.Type == astColumnName && ok && (hasCondition(col, "PRIMARY KEY") || hasCondition(col, "UNIQUE"))
+		}
+		fromRight := func(side *astNode) bool {
+			for name := range right {
+				if referencesColumn(side, name) {
+					return true
 				}
 			}
+			return false
 		}
+		return (isUnique(on.left) && !fromRight(on.right)) || (isUnique(on.right) && !fromRight(on.left))
 	}
+	return false
+}
+
+// countOf is what one source row adds to COUNT(columnName): COUNT(*) counts
+// every row, COUNT(x) skips rows where x is NULL (e.g. outer join padding).
+func countOf(columnName string, row map[string]interface{}) float64 {
+	if columnName != "*" && row[columnName] == nil {
+		return 0
+	}
+	return 1
+}
+
+// aggregateKey is the row key an aggregate result is stored under, e.g.
+// COUNT(has_diabetes). evalExpression uses it to resolve astFunction nodes
+// against result rows.
+func aggregateKey(functionName string, columnName string) string {
+	return strings.ToUpper(functionName) + "(" + columnName + ")"
+}
 
+func columnTypeToFunctionName(ct columnType) string {
+	switch ct {
+	case columnTypeMax:
+		return "MAX"
+	case columnTypeMin:
+		return "MIN"
+	case columnTypeAvg:
+		return "AVG"
+	case columnTypeSum:
+		return "SUM"
+	case columnTypeCount:
+		return "COUNT"
+	default:
+		panic("Not an aggregate column type")
+	}
+}
+
+// resultRowScope exposes the cells of a result row under every name a
+// post-aggregation clause may use for them: the group key, COUNT(x)-style
+// keys and the alias. Hidden aggregates are included since they are noised
+// like the visible ones, but never shadow a visible column.
+func resultRowScope(result dbTable, row map[string]interface{}) map[string]interface{} {
+	scope := make(map[string]interface{}, 2*len(result.Columns))
+	for _, col := range result.Columns {
+		if col.Visible {
+			scope[col.Name] = row[col.Name]
+		}
+	}
+	for _, col := range result.Columns {
+		if !col.Visible && col.FunctionResult {
+			if _, taken := scope[col.Name]; !taken {
+				scope[col.Name] = row[col.Name]
+			}
+		}
+	}
+	for _, col := range result.Columns {
+		if col.Visible && col.Alias != "" {
+			if _, taken := scope[col.Alias]; !taken {
+				scope[col.Alias] = row[col.Name]
+			}
+		}
+	}
+	return scope
+}
+
+// checkReferences panics when a column or aggregate used in a clause doesn't
+// name anything in scope, rather than letting it silently evaluate to NULL.
+func checkReferences(clause string, expr *astNode, scope map[string]interface{}) {
+	switch expr.Type {
+	case astColumnName:
+		if _, ok := scope[expr.columnName]; !ok {
+			panic(fmt.Sprintf("%s: unknown or ambiguous column %q", clause, expr.columnName))
+		}
+	case astFunction:
+		key := functionExpressionKey(expr)
+		if _, ok := scope[key]; !ok {
+			panic(fmt.Sprintf("%s: aggregate %s is not allowed here", clause, key))
+		}
+	case astBinary:
+		checkReferences(clause, expr.left, scope)
+		checkReferences(clause, expr.right, scope)
+	case astUnary:
+		checkReferences(clause, expr.left, scope)
+	}
+}
+
+// checkResultReferences checks the names in HAVING and ORDER BY against the
+// columns of the result, which are the same for every row.
+func checkResultReferences(result dbTable, selectNode *astNode) {
+	scope := resultRowScope(result, nil)
+	if selectNode.havingClause != nil {
+		checkReferences("HAVING", selectNode.havingClause, scope)
+	}
+	for _, term := range selectNode.orderBy {
+		checkReferences("ORDER BY", term.expr, scope)
+	}
+}
+
+// renameColumnReferences returns a copy of expr with every use of the column
+// oldName changed to newName.
+func renameColumnReferences(expr *astNode, oldName string, newName string) *astNode {
+	if expr == nil {
+		return nil
+	}
+	renamed := *expr
+	if renamed.Type == astColumnName && renamed.columnName == oldName {
+		renamed.columnName = newName
+	}
+	renamed.left = renameColumnReferences(expr.left, oldName, newName)
+	renamed.right = renameColumnReferences(expr.right, oldName, newName)
+	if expr.functionArguements != nil {
+		renamed.functionArguements = make([]*astNode, len(expr.functionArguements))
+		for i, arg := range expr.functionArguements {
+			renamed.functionArguements[i] = renameColumnReferences(arg, oldName, newName)
+		}
+	}
+	return &renamed
+}
+
+// needsHiddenCount reports whether a query releases the hidden row count,
+// which HAVING and ORDER BY can name.
+func needsHiddenCount(selectNode *astNode) bool {
+	if selectNode.havingClause != nil && referencesColumn(selectNode.havingClause, "count") {
+		return true
+	}
+	for _, term := range selectNode.orderBy {
+		if referencesColumn(term.expr, "count") {
+			return true
+		}
+	}
+	return false
+}
+
+// referencesColumn reports whether expr uses the column name.
+func referencesColumn(expr *astNode, name string) bool {
+	switch expr.Type {
+	case astColumnName:
+		return expr.columnName == name
+	case astBinary:
+		return referencesColumn(expr.left, name) || referencesColumn(expr.right, name)
+	case astUnary:
+		return referencesColumn(expr.left, name)
+	case astFunction:
+		for _, arg := range expr.functionArguements {
+			if referencesColumn(arg, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func functionExpressionKey(expr *astNode) string {
+	argName := ""
+	if len(expr.functionArguements) == 1 && expr.functionArguements[0].Type == astColumnName {
+		argName = expr.functionArguements[0].columnName
+	}
+	return aggregateKey(expr.functionName, argName)
+}
+
+// applyOrderBy sorts result rows by the query's ORDER BY terms.
+// QueryStatement calls it after noise has been added so the order never
+// reflects un-noised values.
+func applyOrderBy(result dbTable, selectNode *astNode) dbTable {
+	if len(selectNode.orderBy) == 0 {
+		return result
+	}
+
+	// evaluate every sort key once per row
+	keys := make([][]interface{}, len(result.Rows))
+	for ri, row := range result.Rows {
+		scope := resultRowScope(result, row)
+		keys[ri] = make([]interface{}, len(selectNode.orderBy))
+		for ti, term := range selectNode.orderBy {
+			keys[ri][ti] = evalExpression(term.expr, scope)
+		}
+	}
+
+	order := make([]int, len(result.Rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		for ti, term := range selectNode.orderBy {
+			av, bv := keys[order[a]][ti], keys[order[b]][ti]
+			if av == nil || bv == nil {
+				if av == nil && bv == nil {
+					continue
+				}
+				return (av == nil) == term.nullsFirst
+			}
+			cmp, _ := compareValues(av, bv)
+			if cmp == 0 {
+				continue
+			}
+			if term.descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+
+	sorted := make([]map[string]interface{}, len(order))
+	for i, ri := range order {
+		sorted[i] = result.Rows[ri]
+	}
+	result.Rows = sorted
+	return result
+}
+
+// applyHaving keeps the result rows that satisfy the HAVING clause. Like
+// applyOrderBy it runs on noised aggregates, so it is pure post-processing.
+func applyHaving(result dbTable, selectNode *astNode) dbTable {
+	if selectNode.havingClause == nil {
+		return result
+	}
+	kept := make([]map[string]interface{}, 0, len(result.Rows))
+	for _, row := range result.Rows {
+		scope := resultRowScope(result, row)
+		if isTruthy(evalExpression(selectNode.havingClause, scope)) {
+			kept = append(kept, row)
+		}
+	}
+	result.Rows = kept
+	return result
+}
+
+// applyLimitOffset drops the first OFFSET rows and keeps at most LIMIT of the
+// rest.
+func applyLimitOffset(result dbTable, selectNode *astNode) dbTable {
+	rows := result.Rows
+	if selectNode.containsOffset {
+		if selectNode.offset >= len(rows) {
+			rows = rows[:0]
+		} else {
+			rows = rows[selectNode.offset:]
+		}
+	}
+	if selectNode.containsLimit && selectNode.limit < len(rows) {
+		rows = rows[:selectNode.limit]
+	}
+	result.Rows = rows
 	return result
 }
 
-func evalExpression(expr *ASTNode, row map[string]interface{}) interface{} {
+// rowMatches reports whether a row satisfies a WHERE clause. A missing
+// clause matches every row; NULL (unknown) counts as not matching.
+func rowMatches(whereClause *astNode, row map[string]interface{}) bool {
+	if whereClause == nil {
+		return true
+	}
+	return isTruthy(evalExpression(whereClause, row))
+}
+
+func evalExpression(expr *astNode, row map[string]interface{}) interface{} {
 	switch expr.Type {
-	case AST_INT_LITERAL:
+	case astIntLiteral:
 		return expr.intVal
-	case AST_FLOAT_LITERAL:
+	case astFloatLiteral:
 		return expr.floatVal
-	case AST_VARCHAR_LITERAL:
+	case astVarcharLiteral:
 		return expr.strVal
-	case AST_BOOLEAN_LITERAL:
+	case astBooleanLiteral:
 		return expr.boolValue
-	case AST_COLUMN_NAME:
+	case astColumnName:
 		return row[expr.columnName]
-	case AST_BINARY:
+	case astFunction:
+		// only meaningful against an aggregated result row
+		return row[functionExpressionKey(expr)]
+	case astUnary:
+		operand := evalExpression(expr.left, row)
+		switch expr.operator {
+		case "NOT":
+			if operand == nil {
+				return nil
+			}
+			return !isTruthy(operand)
+		case "IS NULL":
+			return operand == nil
+		case "IS NOT NULL":
+			return operand != nil
+		default:
+			panic("Unsupported operator: " + expr.operator)
+		}
+	case astBinary:
+		// AND/OR use SQL three-valued logic, where nil stands for unknown.
+		if expr.operator == "AND" || expr.operator == "OR" {
+			left := evalExpression(expr.left, row)
+			if left != nil && isTruthy(left) == (expr.operator == "OR") {
+				return expr.operator == "OR"
+			}
+			right := evalExpression(expr.right, row)
+			if right != nil && isTruthy(right) == (expr.operator == "OR") {
+				return expr.operator == "OR"
+			}
+			if left == nil || right == nil {
+				return nil
+			}
+			return expr.operator == "AND"
+		}
+
 		left := evalExpression(expr.left, row)
 		right := evalExpression(expr.right, row)
 
-		if expr.operator == "+" || expr.operator == "-" || expr.operator == "*" || expr.operator == "/" { // Assume numeric for now (extend as needed)
+		if expr.operator == "+" || expr.operator == "-" || expr.operator == "*" || expr.operator == "/" || expr.operator == "%" { // Assume numeric for now (extend as needed)
 			leftVal := toFloat64(left)
 			rightVal := toFloat64(right)
 
@@ -328,11 +1406,36 @@ func evalExpression(expr *ASTNode, row map[string]interface{}) interface{} {
 					return float64(0) // Avoid divide-by-zero panic
 				}
 				return leftVal / rightVal
+			case "%":
+				if rightVal == 0 {
+					return float64(0)
+				}
+				return math.Mod(leftVal, rightVal)
 			default:
 				panic("Unsupported operator: " + expr.operator)
 			}
-		} else {
-			panic("Not implemented")
+		}
+
+		// Comparisons against NULL are unknown.
+		cmp, ok := compareValues(left, right)
+		if !ok {
+			return nil
+		}
+		switch expr.operator {
+		case "=":
+			return cmp == 0
+		case "<>":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case ">":
+			return cmp > 0
+		case "<=":
+			return cmp <= 0
+		case ">=":
+			return cmp >= 0
+		default:
+			panic("Unsupported operator: " + expr.operator)
 		}
 	default:
 		panic(fmt.Sprintf("Unsupported AST node type in evalExpression: %d", expr.Type))
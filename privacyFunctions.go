@@ -1,89 +1,160 @@
-package main
+package dpsql
 
 import (
 	"fmt"
 	"math"
-	"math/rand"
+	"strconv"
 	"strings"
 )
 
-// Laplace Noise Function
-func sampleLaplace(b float64) float64 {
-	// Generate a uniform random number in [0,1)
-	u := rand.Float64()
-	// Use the inverse CDF method based on u:
-	if u < 0.5 {
-		return b * math.Log(2*u)
-	}
-	return -b * math.Log(2*(1-u))
+// noisyAverage divides a noisy sum by a noisy count. A count that noise has
+// pushed below one row is taken as one, so a small group can't blow the
+// average up or flip its sign, and the result is clamped back into the
+// column's bounds, where every true average lies.
+func noisyAverage(noisySum float64, noisyCount float64, bounds valueBounds) float64 {
+	return bounds.clamp(noisySum / math.Max(noisyCount, 1)).(float64)
 }
 
-func addNoise(trueValue float64, epsilon float64, sensitivity float64) float64 {
+func addNoise(trueValue float64, epsilon float64, sensitivity float64, source NoiseSource) float64 {
 	// Calculate the scale parameter for the Laplace distribution
 	b := sensitivity / epsilon
 
-	// Sample from the Laplace distribution
-	noise := sampleLaplace(b)
-
-	// Add noise to the true value
-	return trueValue + noise
+	// Sample from the Laplace distribution around the true value
+	return sampleLaplace(trueValue, b, sensitivity, source)
 }
 
-// enforceKAnonymity removes any row whose combination of quasi‑identifiers
-// appears fewer than k times. If quasiIDs is empty or nil, it defaults to
-// using all visible columns in the table as quasi‑identifiers.
-func enforceKAnonymity(table Table, quasiIDs []string, k int) Table {
-	// If no quasi‑IDs specified, gather all visible column names
-	if len(quasiIDs) == 0 {
-		for _, col := range table.Columns {
-			if col.Visible {
-				quasiIDs = append(quasiIDs, col.Name)
-			}
+// newNoiser returns what adds noise to one aggregate of a result row, given
+// its column and true value. sensitivities holds every aggregate the query
+// releases, hidden ones included, and together they spend ε and δ: under
+// Laplace each gets an equal share of ε, so an AVG's sum and the COUNT it's
+// divided by are two of them. Under Gaussian the aggregates of a row
+// share ε and δ through the L2 sensitivity of the whole row.
+func newNoiser(mechanism Mechanism, epsilon float64, delta float64, sensitivities map[string]aggregateSensitivity, source NoiseSource) func(col string, v float64) float64 {
+	if mechanism == Gaussian {
+		values := make(map[string]float64, len(sensitivities))
+		for col, sensitivity := range sensitivities {
+			values[col] = sensitivity.value()
+		}
+		sigmas, gammas := gaussianScale(epsilon, delta, values)
+		return func(col string, v float64) float64 {
+			return sampleGaussian(v, sigmas[col], gammas[col], source)
 		}
 	}
 
-	// check if we have a hidden "count" column
-	hasCountCol := false
-	for _, col := range table.Columns {
-		if col.Name == "count" {
-			hasCountCol = true
-			break
+	// sequential composition: m aggregates at ε/m each spend ε in all
+	share := epsilon
+	if len(sensitivities) > 0 {
+		share = epsilon / float64(len(sensitivities))
+	}
+	return func(col string, v float64) float64 {
+		return addNoise(v, share, sensitivities[col].value(), source)
+	}
+}
+
+// valueBounds is the range a column's values are clamped to before they go
+// into an aggregate, declared with BOUNDS(lo, hi).
+type valueBounds struct {
+	lo, hi float64
+	set    bool
+}
+
+// formatBounds is how BOUNDS is kept in Column.Conditions.
+func formatBounds(lo float64, hi float64) string {
+	return "BOUNDS(" + strconv.FormatFloat(lo, 'g', -1, 64) + ", " + strconv.FormatFloat(hi, 'g', -1, 64) + ")"
+}
+
+// columnBounds returns the BOUNDS declared on a column, if any.
+func columnBounds(col dbColumn) (valueBounds, bool) {
+	for _, condition := range col.Conditions {
+		var b valueBounds
+		if _, err := fmt.Sscanf(condition, "BOUNDS(%g, %g)", &b.lo, &b.hi); err == nil {
+			b.set = true
+			return b, true
 		}
 	}
+	return valueBounds{}, false
+}
 
-	var filtered []map[string]interface{}
-	if hasCountCol {
-		// use the precomputed count value
-		for _, row := range table.Rows {
-			// assume count is stored as float64
-			cnt, _ := row["count"].(float64)
-			if int(cnt) >= k {
-				filtered = append(filtered, row)
-			}
+// clamp moves a value into the bounds. NULL stays NULL.
+func (b valueBounds) clamp(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return math.Min(math.Max(toFloat64(v), b.lo), b.hi)
+}
+
+// aggregateSensitivity is what sets the noise of one aggregate column: the
+// aggregate and the bounds of its (clamped) column. An AVG also names the
+// COUNT of its column that its noisy sum is divided by.
+type aggregateSensitivity struct {
+	ct     columnType
+	bounds valueBounds
+	count  string
+}
+
+// value is the L1 sensitivity of the aggregate, the most that adding or
+// removing one row can move it. A row moves a COUNT by 1 and a SUM by at
+// most the larger bound, and can drag a MIN or MAX anywhere in the bounds.
+// For AVG it is the sensitivity of the sum the average is taken from.
+func (s aggregateSensitivity) value() float64 {
+	switch s.ct {
+	case columnTypeSum, columnTypeAvg:
+		return math.Max(math.Abs(s.bounds.lo), math.Abs(s.bounds.hi))
+	case columnTypeMin, columnTypeMax:
+		return s.bounds.hi - s.bounds.lo
+	default:
+		return 1
+	}
+}
+
+// enforceKAnonymity removes the result rows whose combination of
+// quasi-identifiers is shared by fewer than k rows of the source table. Rows
+// are grouped by the quasi-identifier columns the result shows, and a
+// group's size is the sum of its rows' noisy hidden counts, so the check
+// only looks at what noise already protects. A quasi-identifier the result
+// doesn't show can't single a row out and is left out of the grouping. If
+// quasiIDs is empty, every group key of the result is one.
+func enforceKAnonymity(table dbTable, quasiIDs []string, k int) dbTable {
+	if k <= 0 {
+		return table
+	}
+	wanted := make(map[string]bool, len(quasiIDs))
+	for _, name := range quasiIDs {
+		wanted[name] = true
+	}
+	var shown []string
+	for _, col := range table.Columns {
+		if !col.Visible || col.FunctionResult {
+			continue
 		}
-	} else {
-		// fallback: recompute frequencies per tuple
-		freq := make(map[string]int, len(table.Rows))
-		for _, row := range table.Rows {
-			parts := make([]string, len(quasiIDs))
-			for i, col := range quasiIDs {
-				parts[i] = fmt.Sprintf("%v", row[col])
-			}
-			key := strings.Join(parts, "|")
-			freq[key]++
+		// m.age in a join is the age quasi-identifier too
+		baseName := col.Name
+		if dot := strings.LastIndex(baseName, "."); dot >= 0 {
+			baseName = baseName[dot+1:]
 		}
-		for _, row := range table.Rows {
-			parts := make([]string, len(quasiIDs))
-			for i, col := range quasiIDs {
-				parts[i] = fmt.Sprintf("%v", row[col])
-			}
-			key := strings.Join(parts, "|")
-			if freq[key] >= k {
-				filtered = append(filtered, row)
-			}
+		if len(quasiIDs) == 0 || wanted[col.Name] || wanted[baseName] || (col.Alias != "" && wanted[col.Alias]) {
+			shown = append(shown, col.Name)
 		}
 	}
 
+	keyOf := func(row map[string]interface{}) string {
+		parts := make([]string, len(shown))
+		for i, col := range shown {
+			parts[i] = fmt.Sprintf("%v", row[col])
+		}
+		return strings.Join(parts, "|")
+	}
+	sizes := make(map[string]float64, len(table.Rows))
+	for _, row := range table.Rows {
+		sizes[keyOf(row)] += toFloat64(row["count"])
+	}
+
+	var filtered []map[string]interface{}
+	for _, row := range table.Rows {
+		if sizes[keyOf(row)] >= float64(k) {
+			filtered = append(filtered, row)
+		}
+	}
 	table.Rows = filtered
 	return table
 }
@@ -91,7 +162,7 @@ func enforceKAnonymity(table Table, quasiIDs []string, k int) Table {
 // enforceLDiversity filters out any row whose equivalence class (defined by
 // all visible columns) has fewer than l distinct values of the specified
 // sensitive attribute.
-func enforceLDiversity(table Table, colsToCheck []string, l int) Table {
+func enforceLDiversity(table dbTable, colsToCheck []string, l int) dbTable {
 
 	// build a set for quick lookup of which cols to enforce
 	checkSet := make(map[string]struct{}, len(colsToCheck))
@@ -113,7 +184,7 @@ func enforceLDiversity(table Table, colsToCheck []string, l int) Table {
 	// 2) Build new list of columns:
 	//    - if col.Name is in checkSet, keep only if unique count >= l
 	//    - otherwise always keep
-	keptCols := make([]Column, 0, len(table.Columns))
+	keptCols := make([]dbColumn, 0, len(table.Columns))
 	for _, col := range table.Columns {
 		if _, toCheck := checkSet[col.Name]; toCheck {
 			if len(uniqueVals[col.Name]) >= l {
@@ -1,12 +1,7 @@
-package main
-
-import (
-	"fmt"
-	"strings"
-)
+package dpsql
 
 // Add Visible here:
-type Column struct {
+type dbColumn struct {
 	Name           string
 	Alias          string
 	Type           string
@@ -16,14 +11,15 @@ type Column struct {
 	Visible        bool
 }
 
-type Table struct {
+type dbTable struct {
 	Name    string
-	Columns []Column
+	Columns []dbColumn
 	Rows    []map[string]interface{}
+	// last value handed out per AUTO_INCREMENT column
+	AutoIncrement map[string]int
+	ForeignKeys   []foreignKey
 }
 
-var database = make(map[string]Table)
-
 /*
 func processSelectFromTable(command string) Table {
 	tokens := strings.Fields(command)
@@ -136,67 +132,22 @@ func processSelectFromTable(command string) Table {
 	return resultTable
 }
 */
-// printTable prints a single table in grid format.
-
-// ------------------- PRINT only Visible columns -------------------
-
-func printTable(table Table) {
-	// filter only visible columns
-	visCols := []Column{}
+// visibleColumns returns the columns a result shows and their labels (the
+// alias if there is one).
+func visibleColumns(table dbTable) ([]dbColumn, []string) {
+	visCols := []dbColumn{}
+	labels := []string{}
 	for _, c := range table.Columns {
 		if c.Visible {
 			visCols = append(visCols, c)
-		}
-	}
-	if len(visCols) == 0 {
-		fmt.Println("Empty result")
-		return
-	}
-
-	// compute widths based on alias or name
-	widths := make([]int, len(visCols))
-	labels := make([]string, len(visCols))
-	for i, col := range visCols {
-		label := col.Name
-		if col.Alias != "" {
-			label = col.Alias
-		}
-		labels[i] = label
-		widths[i] = len(label)
-	}
-	for _, row := range table.Rows {
-		for i, col := range visCols {
-			cell := fmt.Sprintf("%v", row[col.Name])
-			if len(cell) > widths[i] {
-				widths[i] = len(cell)
+			if c.Alias != "" {
+				labels = append(labels, c.Alias)
+			} else {
+				labels = append(labels, c.Name)
 			}
 		}
 	}
-
-	// separator line
-	sep := "+"
-	for _, w := range widths {
-		sep += strings.Repeat("-", w+2) + "+"
-	}
-
-	// header
-	header := "|"
-	for i, label := range labels {
-		header += " " + fmt.Sprintf("%-*s", widths[i], label) + " |"
-	}
-
-	fmt.Println(sep)
-	fmt.Println(header)
-	fmt.Println(sep)
-	for _, row := range table.Rows {
-		line := "|"
-		for i, col := range visCols {
-			cell := fmt.Sprintf("%v", row[col.Name])
-			line += " " + fmt.Sprintf("%-*s", widths[i], cell) + " |"
-		}
-		fmt.Println(line)
-	}
-	fmt.Println(sep)
+	return visCols, labels
 }
 
 // Existing helper functions
@@ -292,74 +243,46 @@ func printTable(table Table) {
 // 	fmt.Printf("Inserted row into %s\n", tableName)
 // }
 
-func checkUnique(tableName string, columnName string, value string) bool {
-	for _, row := range database[tableName].Rows {
-		if row[columnName] == value {
+// checkUnique reports whether no row other than skipRow already holds value
+// in columnName. Pass -1 to check every row.
+func checkUnique(rows []map[string]interface{}, columnName string, value interface{}, skipRow int) bool {
+	for i, row := range rows {
+		if i != skipRow && row[columnName] == value {
 			return false
 		}
 	}
 	return true
 }
 
-func createColumn(newName string, newType string, newConditions []string) Column {
-	return Column{Name: newName, Type: newType, Conditions: newConditions}
+func createColumn(newName string, newType string, newConditions []string) dbColumn {
+	return dbColumn{Name: newName, Type: newType, Conditions: newConditions}
 }
 
-func createTable(newName string, newColumns []Column) {
-	if tableExists(newName) {
+func (db *DB) createTable(newName string, newColumns []dbColumn) {
+	if db.tableExists(newName) {
 		// Error table exists
 		return
 	}
-	database[newName] = Table{
-		Name:    newName,
-		Columns: newColumns,
+	db.tables[newName] = dbTable{
+		Name:          newName,
+		Columns:       newColumns,
+		AutoIncrement: make(map[string]int),
 	}
 }
 
-func printDatabase() {
-	fmt.Println("Current Database State:")
-	for tableName, table := range database {
-		fmt.Printf("Table: %s\n", tableName)
-		cols := make([]string, len(table.Columns))
-		widths := make([]int, len(table.Columns))
-		for i, col := range table.Columns {
-			cols[i] = col.Name
-			widths[i] = len(col.Name)
-		}
-		for _, row := range table.Rows {
-			for i, col := range table.Columns {
-				cellStr := fmt.Sprintf("%v", row[col.Name])
-				if len(cellStr) > widths[i] {
-					widths[i] = len(cellStr)
-				}
-			}
-		}
-		sep := "+"
-		for _, w := range widths {
-			sep += strings.Repeat("-", w+2) + "+"
-		}
-		header := "|"
-		for i, name := range cols {
-			header += " " + fmt.Sprintf("%-*s", widths[i], name) + " |"
-		}
-		fmt.Println(sep)
-		fmt.Println(header)
-		fmt.Println(sep)
-		for _, row := range table.Rows {
-			rowStr := "|"
-			for i, col := range table.Columns {
-				cellStr := fmt.Sprintf("%v", row[col.Name])
-				rowStr += " " + fmt.Sprintf("%-*s", widths[i], cellStr) + " |"
-			}
-			fmt.Println(rowStr)
+// hasCondition reports whether a column carries a constraint such as
+// "NOT NULL" or "AUTO_INCREMENT".
+func hasCondition(col dbColumn, condition string) bool {
+	for _, c := range col.Conditions {
+		if c == condition {
+			return true
 		}
-		fmt.Println(sep)
-		fmt.Println("")
 	}
+	return false
 }
 
-func tableExists(tableName string) bool {
-	_, exists := database[tableName]
+func (db *DB) tableExists(tableName string) bool {
+	_, exists := db.tables[tableName]
 	return exists
 }
 
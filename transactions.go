@@ -0,0 +1,123 @@
+package dpsql
+
+import (
+	"errors"
+	"fmt"
+)
+
+// savepoint is a named copy of the database inside a transaction.
+type savepoint struct {
+	name     string
+	snapshot map[string]dbTable
+}
+
+// transaction holds what ROLLBACK returns to: the database as it was at
+// BEGIN, and at each SAVEPOINT since.
+type transaction struct {
+	snapshot   map[string]dbTable
+	savepoints []savepoint
+}
+
+// copyDatabase deep-copies every table, so later statements can't reach the
+// copy through shared rows, columns or maps.
+func (db *DB) copyDatabase() map[string]dbTable {
+	copied := make(map[string]dbTable, len(db.tables))
+	for name, table := range db.tables {
+		columns := make([]dbColumn, len(table.Columns))
+		for i, col := range table.Columns {
+			col.Conditions = append([]string{}, col.Conditions...)
+			columns[i] = col
+		}
+		rows := make([]map[string]interface{}, len(table.Rows))
+		for i, row := range table.Rows {
+			newRow := make(map[string]interface{}, len(row))
+			for k, v := range row {
+				newRow[k] = v
+			}
+			rows[i] = newRow
+		}
+		autoIncrement := make(map[string]int, len(table.AutoIncrement))
+		for k, v := range table.AutoIncrement {
+			autoIncrement[k] = v
+		}
+		foreignKeys := make([]foreignKey, len(table.ForeignKeys))
+		for i, fk := range table.ForeignKeys {
+			fk.Columns = append([]string{}, fk.Columns...)
+			fk.RefColumns = append([]string{}, fk.RefColumns...)
+			foreignKeys[i] = fk
+		}
+
+		copied[name] = dbTable{
+			Name:          table.Name,
+			Columns:       columns,
+			Rows:          rows,
+			AutoIncrement: autoIncrement,
+			ForeignKeys:   foreignKeys,
+		}
+	}
+	return copied
+}
+
+// transactionFromAST runs BEGIN, COMMIT, ROLLBACK, SAVEPOINT, ROLLBACK TO and
+// RELEASE.
+func (db *DB) transactionFromAST(transactionNode *astNode) (Result, error) {
+	action := transactionNode.transactionAction
+	if action == "BEGIN" {
+		if db.tx != nil {
+			return Result{}, errors.New("A transaction is already in progress")
+		}
+		db.tx = &transaction{snapshot: db.copyDatabase()}
+		return Result{Message: "Started transaction"}, nil
+	}
+
+	if db.tx == nil {
+		return Result{}, fmt.Errorf("%s: no transaction in progress", action)
+	}
+
+	switch action {
+	case "COMMIT":
+		db.tx = nil
+		return Result{Message: "Committed transaction"}, nil
+
+	case "ROLLBACK":
+		db.rollbackTransaction()
+		return Result{Message: "Rolled back transaction"}, nil
+
+	case "SAVEPOINT":
+		db.tx.savepoints = append(db.tx.savepoints,
+			savepoint{name: transactionNode.name, snapshot: db.copyDatabase()})
+		return Result{Message: fmt.Sprintf("Created savepoint %s", transactionNode.name)}, nil
+
+	case "ROLLBACK TO", "RELEASE":
+		// the most recent savepoint of that name wins, as in SQL
+		index := -1
+		for i := len(db.tx.savepoints) - 1; i >= 0; i-- {
+			if db.tx.savepoints[i].name == transactionNode.name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return Result{}, fmt.Errorf("Savepoint %s does not exist", transactionNode.name)
+		}
+
+		if action == "RELEASE" {
+			// forget it and every later savepoint, keeping the changes
+			db.tx.savepoints = db.tx.savepoints[:index]
+			return Result{Message: fmt.Sprintf("Released savepoint %s", transactionNode.name)}, nil
+		}
+		// the savepoint stays, so it can be rolled back to again
+		db.tables = db.tx.savepoints[index].snapshot
+		db.tx.savepoints[index].snapshot = db.copyDatabase()
+		db.tx.savepoints = db.tx.savepoints[:index+1]
+		return Result{Message: fmt.Sprintf("Rolled back to savepoint %s", transactionNode.name)}, nil
+	}
+	return Result{}, nil
+}
+
+// rollbackTransaction restores the database to BEGIN and ends the
+// transaction.
+func (db *DB) rollbackTransaction() {
+	db.tables = db.tx.snapshot
+	db.tx = nil
+}
@@ -0,0 +1,138 @@
+package dpsql
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// columnValues lists one column of a table, in row order.
+func columnValues(db *DB, table string, column string) string {
+	var values []interface{}
+	for _, row := range db.tables[table].Rows {
+		values = append(values, row[column])
+	}
+	return fmt.Sprint(values)
+}
+
+func TestTransactions(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+		// the last statement of script fails
+		fails bool
+	}{
+		{"commit", "BEGIN; INSERT INTO t (x) VALUES (2); COMMIT;", "[1 2]", false},
+		{"rollback", "BEGIN; INSERT INTO t (x) VALUES (2); ROLLBACK;", "[1]", false},
+		{"rollback update and delete", "BEGIN; UPDATE t SET x = 5; DELETE FROM t; ROLLBACK;", "[1]", false},
+		{"rollback to savepoint",
+			"BEGIN; INSERT INTO t (x) VALUES (2); SAVEPOINT a; INSERT INTO t (x) VALUES (3); ROLLBACK TO a; INSERT INTO t (x) VALUES (4); COMMIT;",
+			"[1 2 4]", false},
+		{"savepoint kept after rollback to it",
+			"BEGIN; SAVEPOINT a; INSERT INTO t (x) VALUES (2); ROLLBACK TO SAVEPOINT a; INSERT INTO t (x) VALUES (3); ROLLBACK TO a; COMMIT;",
+			"[1]", false},
+		{"rollback to an earlier savepoint",
+			"BEGIN; SAVEPOINT a; INSERT INTO t (x) VALUES (2); SAVEPOINT b; INSERT INTO t (x) VALUES (3); ROLLBACK TO a; COMMIT;",
+			"[1]", false},
+		{"later savepoints go with a rollback",
+			"BEGIN; SAVEPOINT a; INSERT INTO t (x) VALUES (2); SAVEPOINT b; ROLLBACK TO a; ROLLBACK TO b;",
+			"[1]", true},
+		{"newest savepoint of a name",
+			"BEGIN; SAVEPOINT a; INSERT INTO t (x) VALUES (2); SAVEPOINT a; INSERT INTO t (x) VALUES (3); ROLLBACK TO a; COMMIT;",
+			"[1 2]", false},
+		{"release keeps changes",
+			"BEGIN; SAVEPOINT a; INSERT INTO t (x) VALUES (2); RELEASE SAVEPOINT a; COMMIT;",
+			"[1 2]", false},
+		{"rollback after release",
+			"BEGIN; SAVEPOINT a; INSERT INTO t (x) VALUES (2); RELEASE a; ROLLBACK;",
+			"[1]", false},
+		{"released savepoint is gone",
+			"BEGIN; SAVEPOINT a; RELEASE a; ROLLBACK TO a;",
+			"[1]", true},
+		{"commit without begin", "COMMIT;", "[1]", true},
+		{"savepoint without begin", "SAVEPOINT a;", "[1]", true},
+		{"nested begin", "BEGIN; INSERT INTO t (x) VALUES (2); BEGIN;", "[1 2]", true},
+		{"unknown savepoint", "BEGIN; ROLLBACK TO nope;", "[1]", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := Open(DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if _, err := db.Exec("CREATE TABLE t (x INT); INSERT INTO t (x) VALUES (1);"); err != nil {
+				t.Fatal(err)
+			}
+			_, err = db.Exec(test.script)
+			if (err != nil) != test.fails {
+				t.Fatalf("error %v, want failure = %v", err, test.fails)
+			}
+			if got := columnValues(db, "t", "x"); got != test.want {
+				t.Errorf("x = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRollbackUndoesSchemaChanges(t *testing.T) {
+	db, err := Open(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE t (x INT); INSERT INTO t (x) VALUES (1);
+BEGIN;
+CREATE TABLE u (y INT);
+ALTER TABLE t ADD COLUMN z INT DEFAULT 7;
+ROLLBACK;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables := db.Tables(); len(tables) != 1 {
+		t.Errorf("tables = %v, want only t", tables)
+	}
+	if columns := db.tables["t"].Columns; len(columns) != 1 {
+		t.Errorf("t has %d columns, want 1", len(columns))
+	}
+	if _, ok := db.tables["t"].Rows[0]["z"]; ok {
+		t.Error("the rolled back column is still in t's row")
+	}
+}
+
+func TestTransactionsReplayAfterCrash(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Path = filepath.Join(t.TempDir(), "db.json")
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE t (x INT); INSERT INTO t (x) VALUES (1);
+BEGIN; INSERT INTO t (x) VALUES (2);
+SAVEPOINT a; INSERT INTO t (x) VALUES (3); ROLLBACK TO a;
+INSERT INTO t (x) VALUES (4); COMMIT;
+BEGIN; INSERT INTO t (x) VALUES (5); SAVEPOINT b; INSERT INTO t (x) VALUES (6);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// crash with the second transaction open
+	db.wal.close()
+	db.ledger.close()
+
+	for i := 0; i < 2; i++ {
+		db, err = Open(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if db.tx != nil {
+			t.Error("the open transaction survived recovery")
+		}
+		if got := columnValues(db, "t", "x"); got != "[1 2 4]" {
+			t.Errorf("after recovery %d, x = %s, want [1 2 4]", i+1, got)
+		}
+		// and again without Close: the ROLLBACK recovery logged must hold
+		db.wal.close()
+		db.ledger.close()
+	}
+}
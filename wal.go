@@ -0,0 +1,196 @@
+package dpsql
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walPathFor is the write-ahead log that sits next to the database file at
+// path: database.json logs to database.wal.
+func walPathFor(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".wal"
+}
+
+// checkpointEvery is how many log records are written before the database
+// file is rewritten and the log emptied.
+const checkpointEvery = 100
+
+// walHeaderSize is the length and checksum in front of every record.
+const walHeaderSize = 8
+
+// A log record is
+//
+//	length   uint32  bytes in the payload
+//	checksum uint32  CRC-32 (IEEE) of the payload
+//	payload:
+//	  lsn    uint64  log sequence number, counting up from 1
+//	  sql    []byte  the statement as it was written
+//
+// all little-endian. A record whose checksum doesn't match, or that runs
+// past the end of the file, is where a crash cut the log short: it and
+// everything after it are dropped.
+
+// walRecord is one statement from the log.
+type walRecord struct {
+	lsn uint64
+	sql string
+}
+
+// writeAheadLog appends mutating statements to disk before they run, so that
+// statements after the last checkpoint can be replayed after a crash.
+type writeAheadLog struct {
+	file *os.File
+	// lsn of the last record written
+	lastLSN uint64
+	// records written since the last checkpoint
+	pending int
+}
+
+// openWriteAheadLog opens (or creates) the log at path and locks it for as
+// long as it is open. Only one handle may write a database: a second one
+// would keep its own tables and LSNs and overwrite the first one's work, so
+// a log that is already locked is an error.
+func openWriteAheadLog(path string) (*writeAheadLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := tryLockFile(file); err != nil {
+		file.Close()
+		if errors.Is(err, errFileLocked) {
+			return nil, fmt.Errorf("%s is in use by another open database", path)
+		}
+		return nil, err
+	}
+	return &writeAheadLog{file: file}, nil
+}
+
+// recover reads the log and returns the records newer than checkpointLSN,
+// the last one the database file already includes. A torn or corrupt tail is
+// cut off so new records follow the last good one, with a note written to
+// log.
+func (wal *writeAheadLog) recover(checkpointLSN uint64, log io.Writer) ([]walRecord, error) {
+	wal.lastLSN = checkpointLSN
+	wal.pending = 0
+	if _, err := wal.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var replay []walRecord
+	var good int64
+	for {
+		record, size, err := readWALRecord(wal.file)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(log, "Ignoring the end of %s: %v\n", wal.file.Name(), err)
+			}
+			break
+		}
+		good += size
+		if record.lsn > wal.lastLSN {
+			wal.lastLSN = record.lsn
+		}
+		if record.lsn > checkpointLSN {
+			replay = append(replay, record)
+			wal.pending++
+		}
+	}
+
+	if err := wal.file.Truncate(good); err != nil {
+		return nil, err
+	}
+	if _, err := wal.file.Seek(good, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// readWALRecord reads the record at the current offset and returns it with
+// its size on disk. It returns io.EOF at a clean end of the log.
+func readWALRecord(r io.Reader) (walRecord, int64, error) {
+	header := make([]byte, walHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF {
+		return walRecord{}, 0, io.EOF
+	}
+	if err != nil {
+		return walRecord{}, 0, fmt.Errorf("torn record header (%d of %d bytes)", n, walHeaderSize)
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	checksum := binary.LittleEndian.Uint32(header[4:8])
+	if length < 8 {
+		return walRecord{}, 0, fmt.Errorf("bad record length %d", length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return walRecord{}, 0, fmt.Errorf("torn record (expected %d bytes)", length)
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return walRecord{}, 0, errors.New("record checksum mismatch")
+	}
+
+	record := walRecord{
+		lsn: binary.LittleEndian.Uint64(payload[0:8]),
+		sql: string(payload[8:]),
+	}
+	return record, int64(walHeaderSize + length), nil
+}
+
+// append writes a statement to the log and syncs it to disk.
+func (wal *writeAheadLog) append(sql string) error {
+	lsn := wal.lastLSN + 1
+	payload := make([]byte, 8+len(sql))
+	binary.LittleEndian.PutUint64(payload[0:8], lsn)
+	copy(payload[8:], sql)
+
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[walHeaderSize:], payload)
+
+	if _, err := wal.file.Write(record); err != nil {
+		return err
+	}
+	if err := wal.file.Sync(); err != nil {
+		return err
+	}
+	wal.lastLSN = lsn
+	wal.pending++
+	return nil
+}
+
+// checkpoint saves tables with everything logged so far and empties
+// the log. The database file is renamed into place before the log is
+// truncated, so a crash in between only means replaying records that are
+// already in it, which their LSNs rule out.
+func (wal *writeAheadLog) checkpoint(path string, tables map[string]dbTable) error {
+	if err := saveDatabase(path, tables, wal.lastLSN); err != nil {
+		return err
+	}
+	if err := wal.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := wal.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	wal.pending = 0
+	return wal.file.Sync()
+}
+
+// checkpointIfDue checkpoints once checkpointEvery records have piled up.
+func (wal *writeAheadLog) checkpointIfDue(path string, tables map[string]dbTable) error {
+	if wal.pending < checkpointEvery {
+		return nil
+	}
+	return wal.checkpoint(path, tables)
+}
+
+func (wal *writeAheadLog) close() error {
+	return wal.file.Close()
+}
@@ -0,0 +1,167 @@
+package dpsql
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openAndRecover opens the log at path and reads it as Open does.
+func openAndRecover(path string, checkpointLSN uint64, log io.Writer) (*writeAheadLog, []walRecord, error) {
+	wal, err := openWriteAheadLog(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	replay, err := wal.recover(checkpointLSN, log)
+	if err != nil {
+		wal.close()
+		return nil, nil, err
+	}
+	return wal, replay, nil
+}
+
+func TestWriteAheadLogReplaysUpToTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.wal")
+	wal, replay, err := openAndRecover(path, 0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay) != 0 {
+		t.Fatalf("new log replays %d records", len(replay))
+	}
+	statements := []string{
+		"CREATE TABLE t (x INT);",
+		"INSERT INTO t (x) VALUES (1);",
+		"INSERT INTO t (x) VALUES (2);",
+	}
+	for _, sql := range statements {
+		if err := wal.append(sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wal.close()
+
+	// a crash partway through the last record
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	var notes strings.Builder
+	wal, replay, err = openAndRecover(path, 0, &notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replay) != 2 {
+		t.Fatalf("replayed %d records, want 2", len(replay))
+	}
+	for i, record := range replay {
+		if record.lsn != uint64(i+1) || record.sql != statements[i] {
+			t.Errorf("record %d = {%d %q}, want {%d %q}", i, record.lsn, record.sql, i+1, statements[i])
+		}
+	}
+	if !strings.Contains(notes.String(), "torn record") {
+		t.Errorf("no note about the torn record, got %q", notes.String())
+	}
+
+	// the torn bytes are gone, so the next record follows the last good one
+	if err := wal.append("INSERT INTO t (x) VALUES (3);"); err != nil {
+		t.Fatal(err)
+	}
+	wal.close()
+	wal, replay, err = openAndRecover(path, 0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.close()
+	if len(replay) != 3 || replay[2].lsn != 3 || replay[2].sql != "INSERT INTO t (x) VALUES (3);" {
+		t.Errorf("after appending, replay = %v, want the two good records and the new one as lsn 3", replay)
+	}
+}
+
+func TestWriteAheadLogSkipsCheckpointedRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.wal")
+	wal, _, err := openAndRecover(path, 0, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sql := range []string{"CREATE TABLE t (x INT);", "INSERT INTO t (x) VALUES (1);"} {
+		if err := wal.append(sql); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wal.close()
+
+	wal, replay, err := openAndRecover(path, 1, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wal.close()
+	if len(replay) != 1 || replay[0].lsn != 2 {
+		t.Errorf("replay after checkpoint 1 = %v, want only lsn 2", replay)
+	}
+	if wal.lastLSN != 2 {
+		t.Errorf("lastLSN = %d, want 2", wal.lastLSN)
+	}
+}
+
+func TestDBRecoversStatementsFromLog(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Path = filepath.Join(t.TempDir(), "db.json")
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE t (x INT); INSERT INTO t (x) VALUES (1); INSERT INTO t (x) VALUES (2);"); err != nil {
+		t.Fatal(err)
+	}
+	// leave without Close, as a crash would: only the log has the rows
+	db.wal.close()
+	db.ledger.close()
+
+	db, err = Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if tables := db.Tables(); len(tables) != 1 || len(db.tables["t"].Rows) != 2 {
+		t.Errorf("recovered tables %v, want t with 2 rows", tables)
+	}
+}
+
+func TestOpenRefusesDatabaseInUse(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Path = filepath.Join(t.TempDir(), "db.json")
+	first, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.Exec("CREATE TABLE t (x INT); INSERT INTO t (x) VALUES (1);"); err != nil {
+		t.Fatal(err)
+	}
+	if second, err := Open(opts); err == nil {
+		second.Close()
+		t.Fatal("opened a database another handle has open")
+	}
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// once the first handle is closed its rows are all there
+	db, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("INSERT INTO t (x) VALUES (2);"); err != nil {
+		t.Fatal(err)
+	}
+	if tables := db.Tables(); len(tables) != 1 || len(db.tables["t"].Rows) != 2 {
+		t.Errorf("tables %v, want t with 2 rows", tables)
+	}
+}